@@ -2,22 +2,35 @@ package chapa
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
 
+	validation "github.com/go-ozzo/ozzo-validation/v4"
 	"github.com/spf13/viper"
 )
 
+// Endpoint paths, relative to the configured base URL and API version.
 const (
-	acceptPaymentV1APIURL  = "https://api.chapa.co/v1/transaction/initialize"
-	verifyPaymentV1APIURL  = "https://api.chapa.co/v1/transaction/verify/%v"
-	transferToBankV1APIURL = "https://api.chapa.co/v1/transfers"
-	transactionsV1APIURL   = "https://api.chapa.co/v1/transactions"
-	banksV1APIURL          = "https://api.chapa.co/v1/banks"
-	bulkTransferAPIURL     = "https://api.chapa.co/v1/bulk-transfers"
+	acceptPaymentPath   = "/transaction/initialize"
+	verifyPaymentPath   = "/transaction/verify/%v"
+	transactionLogPath  = "/transaction/events/%v"
+	transferToBankPath  = "/transfers"
+	verifyTransferPath  = "/transfers/verify/%v"
+	listTransfersPath   = "/transfers"
+	transactionsPath    = "/transactions"
+	banksPath           = "/banks"
+	bulkTransferPath    = "/bulk-transfers"
+	subaccountPath      = "/subaccount"
+	refundPath          = "/refund/%v"
+	directChargePath    = "/charges"
+	authorizeChargePath = "/validate"
 )
 
 type API interface {
@@ -27,250 +40,428 @@ type API interface {
 	GetTransactions() (*TransactionsResponse, error)
 	GetBanks() (*BanksResponse, error)
 	BulkTransfer(*BulkTransferRequest) (*BulkTransferResponse, error)
+
+	// The WithContext variants take a context that controls the lifetime of
+	// the underlying HTTP request, so deadlines and cancellation are honoured.
+	PaymentRequestWithContext(ctx context.Context, request *PaymentRequest) (*PaymentResponse, error)
+	VerifyWithContext(ctx context.Context, txnRef string) (*VerifyResponse, error)
+	TransferToBankWithContext(ctx context.Context, request *BankTransfer) (*BankTransferResponse, error)
+	GetTransactionsWithContext(ctx context.Context) (*TransactionsResponse, error)
+	GetBanksWithContext(ctx context.Context) (*BanksResponse, error)
+	BulkTransferWithContext(ctx context.Context, request *BulkTransferRequest) (*BulkTransferResponse, error)
+
+	// VerifyTransfer fetches the transfer sent with the given merchant reference.
+	VerifyTransfer(ctx context.Context, reference string) (*VerifyTransferResponse, error)
+	// ListTransfers fetches a page of the merchant's transfers. filter may be nil.
+	ListTransfers(ctx context.Context, filter *TransferFilter) (*TransfersResponse, error)
+	// GetBulkTransfer fetches the status of a bulk transfer and of each of its items.
+	GetBulkTransfer(ctx context.Context, batchID int) (*BulkTransferResult, error)
+
+	// ListTransactions fetches a page of the merchant's transactions. filter may be nil.
+	ListTransactions(ctx context.Context, filter *TransactionFilter) (*TransactionsResponse, error)
+	// Transactions returns an iterator over every transaction matching filter,
+	// fetching pages as they are needed. filter may be nil.
+	Transactions(ctx context.Context, filter *TransactionFilter, opts ...IteratorOption) *TransactionIterator
+	// GetTransactionLogs fetches the history of the transaction with txRef, oldest event first.
+	GetTransactionLogs(ctx context.Context, txRef string) (*TransactionLogsResponse, error)
+
+	// CreateSubaccount registers a vendor account that split payments can be routed to.
+	CreateSubaccount(ctx context.Context, request *Subaccount) (*SubaccountResponse, error)
+	// ListSubaccounts fetches the merchant's subaccounts.
+	ListSubaccounts(ctx context.Context) (*SubaccountsResponse, error)
+
+	// Refund returns all or part of a paid transaction to the customer.
+	// Refunds of a transaction are refused once they would exceed its amount.
+	Refund(ctx context.Context, txRef string, request *RefundRequest) (*RefundResponse, error)
+
+	// DirectCharge charges the customer's mobile wallet with method, without
+	// the hosted checkout. The response says how the charge is completed.
+	DirectCharge(ctx context.Context, method PaymentMethod, request *DirectChargeRequest) (*DirectChargeResponse, error)
+	// AuthorizeDirectCharge completes a direct charge with the OTP sent to the customer.
+	AuthorizeDirectCharge(ctx context.Context, reference, otp string) (*AuthorizeDirectChargeResponse, error)
 }
 
 type chapa struct {
-	apiKey string
-	client *http.Client
+	apiKey      string
+	baseURL     string
+	version     string
+	userAgent   string
+	timeout     time.Duration
+	client      *http.Client
+	logger      Logger
+	retry       RetryPolicy
+	idempotency IdempotencyStore
+	refunds     RefundLedger
 }
 
+// New creates a client configured from the API_KEY and TIME_OUT viper keys.
+// The optional BASE_URL and API_VERSION keys override the Chapa defaults.
 func New() API {
-	return &chapa{
-		apiKey: viper.GetString("API_KEY"),
-		client: &http.Client{
-			Timeout: viper.GetDuration("TIME_OUT"),
-		},
+	opts := []Option{WithTimeout(viper.GetDuration("TIME_OUT"))}
+	if viper.IsSet("BASE_URL") {
+		opts = append(opts, WithBaseURL(viper.GetString("BASE_URL")))
+	}
+	if viper.IsSet("API_VERSION") {
+		opts = append(opts, WithAPIVersion(viper.GetString("API_VERSION")))
 	}
+
+	return NewClient(viper.GetString("API_KEY"), opts...)
 }
 
-func (c *chapa) PaymentRequest(request *PaymentRequest) (*PaymentResponse, error) {
-	var err error
-	if err = request.Validate(); err != nil {
-		err := fmt.Errorf("invalid input %v", err)
-		log.Printf("warning %v input %v", err.Error(), request)
-		return &PaymentResponse{}, err
+// endpoint builds the absolute URL of path under the configured base URL and
+// API version. Any args are escaped and substituted into path.
+func (c *chapa) endpoint(path string, args ...string) string {
+	if len(args) > 0 {
+		escaped := make([]interface{}, len(args))
+		for i, arg := range args {
+			escaped[i] = url.PathEscape(arg)
+		}
+		path = fmt.Sprintf(path, escaped...)
 	}
 
-	data, err := json.Marshal(request)
-	if err != nil {
-		return nil, err
+	base := strings.TrimRight(c.baseURL, "/")
+	if version := strings.Trim(c.version, "/"); version != "" {
+		base += "/" + version
 	}
 
-	req, err := http.NewRequest(http.MethodPost, acceptPaymentV1APIURL, bytes.NewBuffer(data))
+	return base + path
+}
+
+// withQuery appends the encoded values to rawURL, if there are any.
+func withQuery(rawURL string, values url.Values) string {
+	if len(values) == 0 {
+		return rawURL
+	}
+	return rawURL + "?" + values.Encode()
+}
+
+// do sends a request with an optional JSON payload and decodes a successful
+// response into out. Responses outside the 2xx range are returned as *APIError.
+func (c *chapa) do(ctx context.Context, method, rawURL string, payload, out interface{}) error {
+	var body io.Reader
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, body)
 	if err != nil {
-		return nil, err
+		c.logger.Printf("error %v", err)
+		return err
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	c.setHeaders(req)
 	req.Close = true
 
 	resp, err := c.client.Do(req)
 	if err != nil {
-		return nil, err
+		c.logger.Printf("error %v", err)
+		return err
 	}
 
 	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	data, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, err
+		c.logger.Printf("error while reading response body %v", err)
+		return err
 	}
 
-	var paymentResponse PaymentResponse
-
-	err = json.Unmarshal(body, &paymentResponse)
-	if err != nil {
-		return nil, err
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return newAPIError(resp.StatusCode, resp.Header, data)
 	}
-	return &paymentResponse, nil
-}
-
-func (c *chapa) Verify(txnRef string) (*VerifyResponse, error) {
 
-	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf(verifyPaymentV1APIURL, txnRef), nil)
-	if err != nil {
-		return nil, err
+	if err = json.Unmarshal(data, out); err != nil {
+		c.logger.Printf("error while unmarshaling  response %v", err)
+		return err
 	}
 
+	return nil
+}
+
+func (c *chapa) setHeaders(req *http.Request) {
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Authorization", "Bearer "+c.apiKey)
-	req.Close = true
+	req.Header.Set("User-Agent", c.userAgent)
+}
 
-	resp, err := c.client.Do(req)
-	if err != nil {
-		return nil, err
-	}
+func (c *chapa) PaymentRequest(request *PaymentRequest) (*PaymentResponse, error) {
+	return c.PaymentRequestWithContext(context.Background(), request)
+}
 
-	defer resp.Body.Close()
+func (c *chapa) PaymentRequestWithContext(ctx context.Context, request *PaymentRequest) (*PaymentResponse, error) {
+	var err error
+	if err = request.Validate(); err != nil {
+		c.logger.Printf("warning %v input %v", err.Error(), request)
+		return &PaymentResponse{}, err
+	}
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
+	var response PaymentResponse
+	if err = c.do(ctx, http.MethodPost, c.endpoint(acceptPaymentPath), request, &response); err != nil {
 		return nil, err
 	}
 
-	var verifyResponse VerifyResponse
+	return &response, nil
+}
+
+func (c *chapa) Verify(txnRef string) (*VerifyResponse, error) {
+	return c.VerifyWithContext(context.Background(), txnRef)
+}
 
-	err = json.Unmarshal(body, &verifyResponse)
+func (c *chapa) VerifyWithContext(ctx context.Context, txnRef string) (*VerifyResponse, error) {
+	var response VerifyResponse
+	err := c.withRetry(ctx, true, func() error {
+		return c.do(ctx, http.MethodGet, c.endpoint(verifyPaymentPath, txnRef), nil, &response)
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	return &verifyResponse, nil
+	return &response, nil
 }
 
 func (c *chapa) TransferToBank(request *BankTransfer) (*BankTransferResponse, error) {
+	return c.TransferToBankWithContext(context.Background(), request)
+}
+
+func (c *chapa) TransferToBankWithContext(ctx context.Context, request *BankTransfer) (*BankTransferResponse, error) {
 	var err error
 	if err = request.Validate(); err != nil {
-		err := fmt.Errorf("invalid input %v", err)
-		log.Printf("warning %v input %v", err, request)
+		c.logger.Printf("warning %v input %v", err, request)
 		return nil, err
 	}
 
-	data, err := json.Marshal(request)
+	var response BankTransferResponse
+	err = c.sendTransfer(ctx, []string{request.Reference}, func() error {
+		return c.do(ctx, http.MethodPost, c.endpoint(transferToBankPath), request, &response)
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	req, err := http.NewRequest(http.MethodPost, transferToBankV1APIURL, bytes.NewBuffer(data))
+	return &response, nil
+}
+
+func (c *chapa) VerifyTransfer(ctx context.Context, reference string) (*VerifyTransferResponse, error) {
+	var response VerifyTransferResponse
+	err := c.withRetry(ctx, true, func() error {
+		return c.do(ctx, http.MethodGet, c.endpoint(verifyTransferPath, reference), nil, &response)
+	})
 	if err != nil {
-		log.Printf("error %v", err)
 		return nil, err
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+c.apiKey)
-	req.Close = true
+	return &response, nil
+}
 
-	resp, err := c.client.Do(req)
+func (c *chapa) ListTransfers(ctx context.Context, filter *TransferFilter) (*TransfersResponse, error) {
+	var response TransfersResponse
+	err := c.withRetry(ctx, true, func() error {
+		return c.do(ctx, http.MethodGet, withQuery(c.endpoint(listTransfersPath), filter.values()), nil, &response)
+	})
 	if err != nil {
-		log.Printf("error %v", err)
 		return nil, err
 	}
 
-	defer resp.Body.Close()
+	return &response, nil
+}
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		log.Printf("error while reading resposne body %v", err)
-		return nil, err
+func (c *chapa) GetBulkTransfer(ctx context.Context, batchID int) (*BulkTransferResult, error) {
+	result := BulkTransferResult{ID: batchID}
+
+	filter := TransferFilter{BatchID: batchID, Page: 1}
+	for {
+		response, err := c.ListTransfers(ctx, &filter)
+		if err != nil {
+			return nil, err
+		}
+
+		result.Transfers = append(result.Transfers, response.Data...)
+		if response.Pagination.NextPageURL == "" || len(response.Data) == 0 {
+			break
+		}
+		filter.Page++
 	}
 
-	var response BankTransferResponse
-	err = json.Unmarshal(body, &response)
-	if err != nil {
-		log.Printf("error while unmarshaling  response %v", err)
-		return nil, err
-	}
-	return &response, nil
+	result.Status = bulkTransferStatus(result.Transfers)
+	return &result, nil
 }
 
 func (c *chapa) GetTransactions() (*TransactionsResponse, error) {
-	req, err := http.NewRequest(http.MethodGet, transactionsV1APIURL, nil)
+	return c.GetTransactionsWithContext(context.Background())
+}
+
+func (c *chapa) GetTransactionsWithContext(ctx context.Context) (*TransactionsResponse, error) {
+	var response TransactionsResponse
+	err := c.withRetry(ctx, true, func() error {
+		return c.do(ctx, http.MethodGet, c.endpoint(transactionsPath), nil, &response)
+	})
 	if err != nil {
-		log.Printf("error %v", err)
 		return nil, err
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	return &response, nil
+}
 
-	resp, err := c.client.Do(req)
+func (c *chapa) ListTransactions(ctx context.Context, filter *TransactionFilter) (*TransactionsResponse, error) {
+	var response TransactionsResponse
+	err := c.withRetry(ctx, true, func() error {
+		return c.do(ctx, http.MethodGet, withQuery(c.endpoint(transactionsPath), filter.values()), nil, &response)
+	})
 	if err != nil {
-		log.Printf("error %v", err)
 		return nil, err
 	}
 
-	body, err := io.ReadAll(resp.Body)
+	return &response, nil
+}
+
+func (c *chapa) Transactions(ctx context.Context, filter *TransactionFilter, opts ...IteratorOption) *TransactionIterator {
+	return NewTransactionIterator(ctx, c, filter, opts...)
+}
+
+func (c *chapa) GetTransactionLogs(ctx context.Context, txRef string) (*TransactionLogsResponse, error) {
+	var response TransactionLogsResponse
+	err := c.withRetry(ctx, true, func() error {
+		return c.do(ctx, http.MethodGet, c.endpoint(transactionLogPath, txRef), nil, &response)
+	})
 	if err != nil {
-		log.Printf("error while reading response body %v", err)
 		return nil, err
 	}
 
-	var response TransactionsResponse
-	err = json.Unmarshal(body, &response)
+	sort.SliceStable(response.Data, func(i, j int) bool {
+		return response.Data[i].CreatedAt.Before(response.Data[j].CreatedAt)
+	})
+
+	return &response, nil
+}
+
+func (c *chapa) GetBanks() (*BanksResponse, error) {
+	return c.GetBanksWithContext(context.Background())
+}
+
+func (c *chapa) GetBanksWithContext(ctx context.Context) (*BanksResponse, error) {
+	var response BanksResponse
+	err := c.withRetry(ctx, true, func() error {
+		return c.do(ctx, http.MethodGet, c.endpoint(banksPath), nil, &response)
+	})
 	if err != nil {
-		log.Printf("error while unmarshaling  response %v", err)
 		return nil, err
 	}
 
 	return &response, nil
 }
 
-func (c *chapa) GetBanks() (*BanksResponse, error) {
-	req, err := http.NewRequest(http.MethodGet, banksV1APIURL, nil)
+func (c *chapa) BulkTransfer(request *BulkTransferRequest) (*BulkTransferResponse, error) {
+	return c.BulkTransferWithContext(context.Background(), request)
+}
+
+func (c *chapa) BulkTransferWithContext(ctx context.Context, request *BulkTransferRequest) (*BulkTransferResponse, error) {
+	var err error
+	if err = request.Validate(); err != nil {
+		c.logger.Printf("warning %v input %v", err, request)
+		return nil, err
+	}
+
+	var response BulkTransferResponse
+	err = c.sendTransfer(ctx, request.references(), func() error {
+		return c.do(ctx, http.MethodPost, c.endpoint(bulkTransferPath), request, &response)
+	})
 	if err != nil {
-		log.Printf("error %v", err)
 		return nil, err
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	return &response, nil
+}
 
-	resp, err := c.client.Do(req)
-	if err != nil {
-		log.Printf("error %v", err)
+func (c *chapa) CreateSubaccount(ctx context.Context, request *Subaccount) (*SubaccountResponse, error) {
+	var err error
+	if err = request.Validate(); err != nil {
+		c.logger.Printf("warning %v input %v", err, request)
 		return nil, err
 	}
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		log.Printf("error while reading response body %v", err)
+	var response SubaccountResponse
+	if err = c.do(ctx, http.MethodPost, c.endpoint(subaccountPath), request, &response); err != nil {
 		return nil, err
 	}
 
-	var response BanksResponse
-	err = json.Unmarshal(body, &response)
+	return &response, nil
+}
+
+func (c *chapa) ListSubaccounts(ctx context.Context) (*SubaccountsResponse, error) {
+	var response SubaccountsResponse
+	err := c.withRetry(ctx, true, func() error {
+		return c.do(ctx, http.MethodGet, c.endpoint(subaccountPath), nil, &response)
+	})
 	if err != nil {
-		log.Printf("error while unmarshaling  response %v", err)
 		return nil, err
 	}
 
 	return &response, nil
 }
 
-func (c *chapa) BulkTransfer(request *BulkTransferRequest) (*BulkTransferResponse, error) {
+func (c *chapa) Refund(ctx context.Context, txRef string, request *RefundRequest) (*RefundResponse, error) {
 	var err error
 	if err = request.Validate(); err != nil {
-		err := fmt.Errorf("invalid input %v", err)
-		log.Printf("warning %v input %v", err, request)
+		c.logger.Printf("warning %v input %v", err, request)
 		return nil, err
 	}
 
-	data, err := json.Marshal(request)
-	if err != nil {
-		return nil, err
+	refund := *request
+	if refund.Reference == "" {
+		refund.Reference = RandomString(16)
 	}
 
-	req, err := http.NewRequest(http.MethodPost, bulkTransferAPIURL, bytes.NewBuffer(data))
+	var response RefundResponse
+	err = c.sendRefund(ctx, txRef, &refund, func() error {
+		return c.do(ctx, http.MethodPost, c.endpoint(refundPath, txRef), &refund, &response)
+	})
 	if err != nil {
-		log.Printf("error %v", err)
 		return nil, err
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+c.apiKey)
-	req.Close = true
+	return &response, nil
+}
 
-	resp, err := c.client.Do(req)
-	if err != nil {
-		log.Printf("error %v", err)
+func (c *chapa) DirectCharge(ctx context.Context, method PaymentMethod, request *DirectChargeRequest) (*DirectChargeResponse, error) {
+	var err error
+	if err = method.validate(); err != nil {
+		c.logger.Printf("warning %v input %v", err, method)
+		return nil, err
+	}
+	if err = request.Validate(); err != nil {
+		c.logger.Printf("warning %v input %v", err, request)
 		return nil, err
 	}
 
-	defer resp.Body.Close()
+	var response DirectChargeResponse
+	rawURL := withQuery(c.endpoint(directChargePath), url.Values{"type": {string(method)}})
+	if err = c.do(ctx, http.MethodPost, rawURL, request, &response); err != nil {
+		return nil, err
+	}
+
+	return &response, nil
+}
 
-	body, err := io.ReadAll(resp.Body)
+func (c *chapa) AuthorizeDirectCharge(ctx context.Context, reference, otp string) (*AuthorizeDirectChargeResponse, error) {
+	err := newValidationError(validation.Errors{
+		"reference": validation.Validate(reference, validation.Required.Error("reference is required")),
+		"otp":       validation.Validate(otp, validation.Required.Error("otp is required")),
+	}.Filter())
 	if err != nil {
-		log.Printf("error while reading resposne body %v", err)
+		c.logger.Printf("warning %v input %v", err, reference)
 		return nil, err
 	}
 
-	response := BulkTransferResponse{}
-	err = json.Unmarshal(body, &response)
-	if err != nil {
-		log.Printf("error while unmarshaling  response %v", err)
+	payload := map[string]string{"reference": reference, "otp": otp}
+
+	var response AuthorizeDirectChargeResponse
+	if err = c.do(ctx, http.MethodPost, c.endpoint(authorizeChargePath), payload, &response); err != nil {
 		return nil, err
 	}
+
 	return &response, nil
 }
@@ -93,7 +93,7 @@ func (s *AppExamplePaymentService) Checkout(ctx context.Context, CustomerID int6
 		TransactionRef: RandomString(10),
 	}
 
-	response, err := s.paymentGatewayProvider.PaymentRequest(invoice)
+	response, err := s.paymentGatewayProvider.PaymentRequestWithContext(ctx, invoice)
 	if err != nil {
 		return &Transaction{}, err
 	}
@@ -1,7 +1,13 @@
 package chapa
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
@@ -68,8 +74,11 @@ func TestChapa(t *testing.T) {
 			}
 
 			response, err := paymentProvider.Verify(request.TransactionRef)
-			assert.NoError(t, err)
-			assert.Equal(t, "Invalid transaction reference", response.Message)
+			assert.Nil(t, response)
+
+			var apiErr *APIError
+			assert.ErrorAs(t, err, &apiErr)
+			assert.Equal(t, "Invalid transaction reference", apiErr.Message)
 		})
 
 		t.Run("successful bank transfer", func(t *testing.T) {
@@ -111,7 +120,7 @@ func TestChapa(t *testing.T) {
 				AccountName:   "Leul Abay Ejigu",
 				AccountNumber: "1000212482106",
 				Amount:        10,
-				Reference:     "3241342142sfdd",
+				Reference:     RandomString(14),
 				BankCode:      "946",
 			}
 
@@ -145,3 +154,302 @@ func TestChapa(t *testing.T) {
 		})
 	})
 }
+
+func TestChapaWithContext(t *testing.T) {
+	paymentProvider := NewClient("")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	t.Run("cancelled context aborts verify", func(t *testing.T) {
+		response, err := paymentProvider.VerifyWithContext(ctx, RandomString(20))
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Nil(t, response)
+	})
+
+	t.Run("cancelled context aborts get banks", func(t *testing.T) {
+		response, err := paymentProvider.GetBanksWithContext(ctx)
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Nil(t, response)
+	})
+}
+
+func TestVerifyTransfer(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/transfers/verify/3241342142sfdd" {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"message":"Invalid transfer reference","status":"failed","data":null}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"message":"Transfer details","status":"success","data":{"account_name":"Leul Abay Ejigu","amount":10,"bank_code":946,"status":"success","tx_ref":"3241342142sfdd"}}`))
+	}))
+	defer server.Close()
+
+	paymentProvider := NewClient("key", WithBaseURL(server.URL))
+
+	t.Run("can verify transfer", func(t *testing.T) {
+		response, err := paymentProvider.VerifyTransfer(context.Background(), "3241342142sfdd")
+		assert.NoError(t, err)
+
+		assert.Equal(t, "Transfer details", response.Message)
+		assert.Equal(t, SuccessTransferStatus, response.Data.Status)
+		assert.Equal(t, "3241342142sfdd", response.Data.Reference)
+		assert.Equal(t, "946", response.Data.BankCode)
+	})
+
+	t.Run("cannot verify unknown transfer", func(t *testing.T) {
+		response, err := paymentProvider.VerifyTransfer(context.Background(), RandomString(14))
+		assert.Nil(t, response)
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+}
+
+func TestListTransfers(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/transfers", r.URL.Path)
+		assert.Equal(t, "from=2024-01-01&page=2&per_page=5&status=failed&to=2024-01-31", r.URL.RawQuery)
+		_, _ = w.Write([]byte(`{
+			"message": "Transfers retrieved",
+			"status": "success",
+			"meta": {"current_page": 2, "per_page": 5, "next_page_url": "https://api.chapa.co/v1/transfers?page=3"},
+			"data": [{"account_name": "Leul Abay Ejigu", "amount": "10.00", "bank_code": 946, "status": "failed", "tx_ref": "ref-1"}]
+		}`))
+	}))
+	defer server.Close()
+
+	paymentProvider := NewClient("key", WithBaseURL(server.URL))
+
+	response, err := paymentProvider.ListTransfers(context.Background(), &TransferFilter{
+		Status:  FailedTransferStatus,
+		From:    time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		To:      time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC),
+		PerPage: 5,
+		Page:    2,
+	})
+	assert.NoError(t, err)
+
+	assert.Equal(t, "Transfers retrieved", response.Message)
+	assert.Equal(t, 2, response.Pagination.CurrentPage)
+	assert.NotEmpty(t, response.Pagination.NextPageURL)
+	assert.Len(t, response.Data, 1)
+	assert.Equal(t, FailedTransferStatus, response.Data[0].Status)
+	assert.Equal(t, "ref-1", response.Data[0].Reference)
+}
+
+func TestListTransactions(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/transactions", r.URL.Path)
+		assert.Equal(t, "currency=ETB&customer=chap%40et.io&from=2024-01-01&page=2&payment_method=telebirr&per_page=20&reference=tx-1&status=success&to=2024-01-31", r.URL.RawQuery)
+		_, _ = w.Write([]byte(`{
+			"message": "Transactions retrieved successfully",
+			"status": "success",
+			"data": {
+				"transactions": [{"status": "success", "trans_id": "tx-1", "amount": "10.00", "currency": "ETB", "payment_method": "telebirr"}],
+				"pagination": {"current_page": 2, "per_page": 20}
+			}
+		}`))
+	}))
+	defer server.Close()
+
+	paymentProvider := NewClient("key", WithBaseURL(server.URL))
+
+	response, err := paymentProvider.ListTransactions(context.Background(), &TransactionFilter{
+		From:          time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		To:            time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC),
+		Status:        SuccessTransactionStatus,
+		Currency:      "ETB",
+		PaymentMethod: "telebirr",
+		Reference:     "tx-1",
+		Customer:      "chap@et.io",
+		PerPage:       20,
+		Page:          2,
+	})
+	assert.NoError(t, err)
+
+	assert.Equal(t, 2, response.Data.Pagination.CurrentPage)
+	assert.Len(t, response.Data.Transactions, 1)
+	assert.Equal(t, "telebirr", response.Data.Transactions[0].PaymentMethod)
+}
+
+func TestGetTransactionLogs(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/transaction/events/tx-1" {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"message":"Invalid transaction reference","status":"failed","data":null}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{
+			"message": "Transaction events fetched",
+			"status": "success",
+			"data": [
+				{"item": 3, "message": "Charged", "type": "log", "created_at": "2024-01-02T09:03:00.000000Z", "updated_at": "2024-01-02T09:03:00.000000Z"},
+				{"item": 1, "message": "Checkout opened", "type": "log", "created_at": "2024-01-02T09:00:00.000000Z", "updated_at": "2024-01-02T09:00:00.000000Z"},
+				{"item": 2, "message": "OTP sent", "type": "log", "created_at": "2024-01-02T09:01:00.000000Z", "updated_at": "2024-01-02T09:01:00.000000Z"}
+			]
+		}`))
+	}))
+	defer server.Close()
+
+	paymentProvider := NewClient("key", WithBaseURL(server.URL))
+
+	t.Run("can get transaction logs in order", func(t *testing.T) {
+		response, err := paymentProvider.GetTransactionLogs(context.Background(), "tx-1")
+		assert.NoError(t, err)
+
+		assert.Equal(t, "Transaction events fetched", response.Message)
+		assert.Len(t, response.Data, 3)
+		assert.Equal(t, []string{"1", "2", "3"}, []string{response.Data[0].Item, response.Data[1].Item, response.Data[2].Item})
+		assert.Equal(t, "OTP sent", response.Data[1].Message)
+		assert.Equal(t, time.Date(2024, 1, 2, 9, 1, 0, 0, time.UTC), response.Data[1].CreatedAt)
+	})
+
+	t.Run("cannot get logs of unknown transaction", func(t *testing.T) {
+		response, err := paymentProvider.GetTransactionLogs(context.Background(), "tx-2")
+		assert.Nil(t, response)
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+}
+
+func TestSubaccount(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/subaccount", r.URL.Path)
+		if r.Method == http.MethodPost {
+			var body map[string]interface{}
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			assert.Equal(t, "percentage", body["split_type"])
+			assert.Equal(t, "0.1", body["split_value"])
+			_, _ = w.Write([]byte(`{"message":"Subaccount created successfully","status":"success","data":{"subaccount_id":"837b4e"}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"message":"Subaccounts retrieved","status":"success","data":[{"id":"837b4e","business_name":"Abebe Souq","bank_code":946}]}`))
+	}))
+	defer server.Close()
+
+	paymentProvider := NewClient("key", WithBaseURL(server.URL))
+
+	t.Run("can create subaccount", func(t *testing.T) {
+		response, err := paymentProvider.CreateSubaccount(context.Background(), &Subaccount{
+			BusinessName:  "Abebe Souq",
+			AccountName:   "Abebe Bikila",
+			BankCode:      "946",
+			AccountNumber: "1000212482106",
+			SplitType:     PercentageSplit,
+			SplitValue:    decimal.RequireFromString("0.1"),
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, "837b4e", response.Data.ID)
+	})
+
+	t.Run("cannot create invalid subaccount", func(t *testing.T) {
+		response, err := paymentProvider.CreateSubaccount(context.Background(), &Subaccount{BusinessName: "Abebe Souq"})
+		assert.Nil(t, response)
+		assert.Contains(t, err.Error(), "invalid input")
+	})
+
+	t.Run("can list subaccounts", func(t *testing.T) {
+		response, err := paymentProvider.ListSubaccounts(context.Background())
+		assert.NoError(t, err)
+		assert.Len(t, response.Data, 1)
+		assert.Equal(t, "946", response.Data[0].BankCode)
+	})
+}
+
+func TestDirectCharge(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		if r.URL.Path == "/v1/validate" {
+			assert.Equal(t, "CHcuKjgnN0Dk0", body["reference"])
+			assert.Equal(t, "123456", body["otp"])
+			_, _ = w.Write([]byte(`{"message":"Payment completed","status":"success","data":{"reference":"CHcuKjgnN0Dk0","status":"success"}}`))
+			return
+		}
+		assert.Equal(t, "/v1/charges", r.URL.Path)
+		assert.Equal(t, "telebirr", r.URL.Query().Get("type"))
+		assert.Equal(t, "0912345678", body["mobile"])
+		_, _ = w.Write([]byte(`{"message":"Charge initiated","status":"success","data":{"auth_type":"ussd","reference":"CHcuKjgnN0Dk0"}}`))
+	}))
+	defer server.Close()
+
+	paymentProvider := NewClient("key", WithBaseURL(server.URL))
+	request := &DirectChargeRequest{
+		Amount:         decimal.NewFromInt(100),
+		Currency:       "ETB",
+		Mobile:         "0912345678",
+		TransactionRef: RandomString(20),
+	}
+
+	t.Run("can charge mobile wallet", func(t *testing.T) {
+		response, err := paymentProvider.DirectCharge(context.Background(), Telebirr, request)
+		assert.NoError(t, err)
+		assert.Equal(t, USSDAuth, response.Data.AuthType)
+		assert.Equal(t, "CHcuKjgnN0Dk0", response.Data.Reference)
+	})
+
+	t.Run("cannot charge unsupported method", func(t *testing.T) {
+		response, err := paymentProvider.DirectCharge(context.Background(), "paypal", request)
+		assert.Nil(t, response)
+		var validationErr *ValidationError
+		assert.True(t, errors.As(err, &validationErr))
+		assert.Contains(t, validationErr.Fields, "method")
+	})
+
+	t.Run("cannot charge invalid mobile", func(t *testing.T) {
+		invalid := *request
+		invalid.Mobile = "12345"
+		response, err := paymentProvider.DirectCharge(context.Background(), Mpesa, &invalid)
+		assert.Nil(t, response)
+		var validationErr *ValidationError
+		assert.True(t, errors.As(err, &validationErr))
+		assert.Contains(t, validationErr.Fields, "mobile")
+	})
+
+	t.Run("can authorize charge with otp", func(t *testing.T) {
+		response, err := paymentProvider.AuthorizeDirectCharge(context.Background(), "CHcuKjgnN0Dk0", "123456")
+		assert.NoError(t, err)
+		assert.Equal(t, SuccessTransactionStatus, response.Data.Status)
+	})
+
+	t.Run("cannot authorize without otp", func(t *testing.T) {
+		response, err := paymentProvider.AuthorizeDirectCharge(context.Background(), "CHcuKjgnN0Dk0", "")
+		assert.Nil(t, response)
+		assert.Contains(t, err.Error(), "otp")
+	})
+}
+
+func TestGetBulkTransfer(t *testing.T) {
+	pages := map[string]string{
+		"1": `{"status":"success","meta":{"current_page":1,"next_page_url":"https://api.chapa.co/v1/transfers?batch_id=42&page=2"},"data":[
+			{"account_name":"Leul Abay Ejigu","account_number":"1000212482106","amount":10,"bank_code":946,"status":"success","tx_ref":"ref-1"}
+		]}`,
+		"2": `{"status":"success","meta":{"current_page":2,"next_page_url":null},"data":[
+			{"account_name":"Yinebeb Tariku","account_number":"34264263","amount":25,"charge":1,"bank_code":946,"status":"failed","failure_reason":"Invalid account number","tx_ref":"ref-2"}
+		]}`,
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "42", r.URL.Query().Get("batch_id"))
+		_, _ = w.Write([]byte(pages[r.URL.Query().Get("page")]))
+	}))
+	defer server.Close()
+
+	paymentProvider := NewClient("key", WithBaseURL(server.URL))
+
+	result, err := paymentProvider.GetBulkTransfer(context.Background(), 42)
+	assert.NoError(t, err)
+
+	assert.Equal(t, 42, result.ID)
+	assert.Equal(t, PartialBulkTransferStatus, result.Status)
+	assert.Len(t, result.Transfers, 2)
+
+	failed := result.Failed()
+	assert.Len(t, failed, 1)
+	assert.Equal(t, "Invalid account number", failed[0].FailureReason)
+	assert.Equal(t, []BulkData{{
+		AccountName:   "Yinebeb Tariku",
+		AccountNumber: "34264263",
+		Amount:        25,
+		Reference:     "ref-2",
+		BankCode:      "946",
+	}}, result.FailedBulkData())
+}
@@ -0,0 +1,118 @@
+package chapamock
+
+import (
+	"context"
+
+	chapa "github.com/Chapa-Et/chapa-go"
+)
+
+var _ chapa.API = (*Mock)(nil)
+
+func (m *Mock) PaymentRequest(request *chapa.PaymentRequest) (*chapa.PaymentResponse, error) {
+	return m.PaymentRequestWithContext(context.Background(), request)
+}
+
+func (m *Mock) PaymentRequestWithContext(ctx context.Context, request *chapa.PaymentRequest) (*chapa.PaymentResponse, error) {
+	response, err := m.call(ctx, PaymentRequest, request)
+	return result[chapa.PaymentResponse](PaymentRequest, response, err)
+}
+
+func (m *Mock) Verify(txnRef string) (*chapa.VerifyResponse, error) {
+	return m.VerifyWithContext(context.Background(), txnRef)
+}
+
+func (m *Mock) VerifyWithContext(ctx context.Context, txnRef string) (*chapa.VerifyResponse, error) {
+	response, err := m.call(ctx, Verify, txnRef)
+	return result[chapa.VerifyResponse](Verify, response, err)
+}
+
+func (m *Mock) TransferToBank(request *chapa.BankTransfer) (*chapa.BankTransferResponse, error) {
+	return m.TransferToBankWithContext(context.Background(), request)
+}
+
+func (m *Mock) TransferToBankWithContext(ctx context.Context, request *chapa.BankTransfer) (*chapa.BankTransferResponse, error) {
+	response, err := m.call(ctx, TransferToBank, request)
+	return result[chapa.BankTransferResponse](TransferToBank, response, err)
+}
+
+func (m *Mock) GetTransactions() (*chapa.TransactionsResponse, error) {
+	return m.GetTransactionsWithContext(context.Background())
+}
+
+func (m *Mock) GetTransactionsWithContext(ctx context.Context) (*chapa.TransactionsResponse, error) {
+	response, err := m.call(ctx, GetTransactions)
+	return result[chapa.TransactionsResponse](GetTransactions, response, err)
+}
+
+func (m *Mock) GetBanks() (*chapa.BanksResponse, error) {
+	return m.GetBanksWithContext(context.Background())
+}
+
+func (m *Mock) GetBanksWithContext(ctx context.Context) (*chapa.BanksResponse, error) {
+	response, err := m.call(ctx, GetBanks)
+	return result[chapa.BanksResponse](GetBanks, response, err)
+}
+
+func (m *Mock) BulkTransfer(request *chapa.BulkTransferRequest) (*chapa.BulkTransferResponse, error) {
+	return m.BulkTransferWithContext(context.Background(), request)
+}
+
+func (m *Mock) BulkTransferWithContext(ctx context.Context, request *chapa.BulkTransferRequest) (*chapa.BulkTransferResponse, error) {
+	response, err := m.call(ctx, BulkTransfer, request)
+	return result[chapa.BulkTransferResponse](BulkTransfer, response, err)
+}
+
+func (m *Mock) VerifyTransfer(ctx context.Context, reference string) (*chapa.VerifyTransferResponse, error) {
+	response, err := m.call(ctx, VerifyTransfer, reference)
+	return result[chapa.VerifyTransferResponse](VerifyTransfer, response, err)
+}
+
+func (m *Mock) ListTransfers(ctx context.Context, filter *chapa.TransferFilter) (*chapa.TransfersResponse, error) {
+	response, err := m.call(ctx, ListTransfers, filter)
+	return result[chapa.TransfersResponse](ListTransfers, response, err)
+}
+
+func (m *Mock) GetBulkTransfer(ctx context.Context, batchID int) (*chapa.BulkTransferResult, error) {
+	response, err := m.call(ctx, GetBulkTransfer, batchID)
+	return result[chapa.BulkTransferResult](GetBulkTransfer, response, err)
+}
+
+func (m *Mock) ListTransactions(ctx context.Context, filter *chapa.TransactionFilter) (*chapa.TransactionsResponse, error) {
+	response, err := m.call(ctx, ListTransactions, filter)
+	return result[chapa.TransactionsResponse](ListTransactions, response, err)
+}
+
+// Transactions iterates over the pages scripted for ListTransactions.
+func (m *Mock) Transactions(ctx context.Context, filter *chapa.TransactionFilter, opts ...chapa.IteratorOption) *chapa.TransactionIterator {
+	return chapa.NewTransactionIterator(ctx, m, filter, opts...)
+}
+
+func (m *Mock) GetTransactionLogs(ctx context.Context, txRef string) (*chapa.TransactionLogsResponse, error) {
+	response, err := m.call(ctx, GetTransactionLogs, txRef)
+	return result[chapa.TransactionLogsResponse](GetTransactionLogs, response, err)
+}
+
+func (m *Mock) CreateSubaccount(ctx context.Context, request *chapa.Subaccount) (*chapa.SubaccountResponse, error) {
+	response, err := m.call(ctx, CreateSubaccount, request)
+	return result[chapa.SubaccountResponse](CreateSubaccount, response, err)
+}
+
+func (m *Mock) ListSubaccounts(ctx context.Context) (*chapa.SubaccountsResponse, error) {
+	response, err := m.call(ctx, ListSubaccounts)
+	return result[chapa.SubaccountsResponse](ListSubaccounts, response, err)
+}
+
+func (m *Mock) Refund(ctx context.Context, txRef string, request *chapa.RefundRequest) (*chapa.RefundResponse, error) {
+	response, err := m.call(ctx, Refund, txRef, request)
+	return result[chapa.RefundResponse](Refund, response, err)
+}
+
+func (m *Mock) DirectCharge(ctx context.Context, method chapa.PaymentMethod, request *chapa.DirectChargeRequest) (*chapa.DirectChargeResponse, error) {
+	response, err := m.call(ctx, DirectCharge, method, request)
+	return result[chapa.DirectChargeResponse](DirectCharge, response, err)
+}
+
+func (m *Mock) AuthorizeDirectCharge(ctx context.Context, reference, otp string) (*chapa.AuthorizeDirectChargeResponse, error) {
+	response, err := m.call(ctx, AuthorizeDirectCharge, reference, otp)
+	return result[chapa.AuthorizeDirectChargeResponse](AuthorizeDirectCharge, response, err)
+}
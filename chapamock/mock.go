@@ -0,0 +1,287 @@
+// Package chapamock provides a scriptable implementation of chapa.API, so that
+// services built on the SDK can be unit tested without an HTTP server.
+//
+// Responses are scripted per method with On, and every call is recorded for
+// the assertion helpers:
+//
+//	mock := chapamock.New()
+//	mock.On(chapamock.Verify).WithArgs("tx-1").Return(&chapa.VerifyResponse{Status: "success"}, nil).Once()
+//	mock.On(chapamock.Verify).Return(nil, chapa.ErrNotFound)
+//
+//	service := NewPaymentService(mock)
+//	...
+//	mock.AssertCalled(t, chapamock.Verify, "tx-1")
+//	mock.AssertExpectations(t)
+//
+// A method and its WithContext variant share one Method name, and the context
+// is not part of the recorded arguments.
+package chapamock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// Method names an API method. The WithContext variant of a method is
+// scripted and recorded under the same name.
+type Method string
+
+const (
+	PaymentRequest  Method = "PaymentRequest"
+	Verify          Method = "Verify"
+	TransferToBank  Method = "TransferToBank"
+	GetTransactions Method = "GetTransactions"
+	GetBanks        Method = "GetBanks"
+	BulkTransfer    Method = "BulkTransfer"
+	VerifyTransfer  Method = "VerifyTransfer"
+	ListTransfers   Method = "ListTransfers"
+	GetBulkTransfer Method = "GetBulkTransfer"
+	// ListTransactions also answers the pages of Transactions iterators.
+	ListTransactions      Method = "ListTransactions"
+	GetTransactionLogs    Method = "GetTransactionLogs"
+	CreateSubaccount      Method = "CreateSubaccount"
+	ListSubaccounts       Method = "ListSubaccounts"
+	Refund                Method = "Refund"
+	DirectCharge          Method = "DirectCharge"
+	AuthorizeDirectCharge Method = "AuthorizeDirectCharge"
+)
+
+// Anything matches any argument in WithArgs and AssertCalled.
+const Anything = "chapamock.Anything"
+
+// ErrUnexpectedCall is returned by calls no stub was scripted for.
+var ErrUnexpectedCall = errors.New("chapamock: unexpected call")
+
+// TestingT is the part of *testing.T used by the assertion helpers.
+type TestingT interface {
+	Errorf(format string, args ...interface{})
+}
+
+// Call is a recorded call to the Mock.
+type Call struct {
+	Method Method
+	Ctx    context.Context
+	// Args are the arguments of the call after the context.
+	Args []interface{}
+}
+
+// Stub scripts the result of calls to one method. Stubs are configured
+// before the Mock is used and are not safe to change concurrently with calls.
+type Stub struct {
+	method   Method
+	args     []interface{}
+	response interface{}
+	err      error
+	times    int
+	calls    int
+	run      func(Call)
+}
+
+// WithArgs restricts the stub to calls with the given arguments, compared
+// with reflect.DeepEqual. Anything matches any argument.
+func (s *Stub) WithArgs(args ...interface{}) *Stub {
+	s.args = args
+	return s
+}
+
+// Return sets the response and error of matching calls. response must be of
+// the method's response type, e.g. *chapa.VerifyResponse for Verify, or nil.
+func (s *Stub) Return(response interface{}, err error) *Stub {
+	s.response = response
+	s.err = err
+	return s
+}
+
+// Times limits the stub to n calls, after which later stubs for the method
+// are used. By default a stub answers any number of calls.
+func (s *Stub) Times(n int) *Stub {
+	s.times = n
+	return s
+}
+
+// Once limits the stub to a single call.
+func (s *Stub) Once() *Stub {
+	return s.Times(1)
+}
+
+// Run calls fn with every call the stub answers, before it returns.
+func (s *Stub) Run(fn func(Call)) *Stub {
+	s.run = fn
+	return s
+}
+
+func (s *Stub) matches(method Method, args []interface{}) bool {
+	if s.method != method {
+		return false
+	}
+	if s.times > 0 && s.calls >= s.times {
+		return false
+	}
+	return s.args == nil || argsMatch(s.args, args)
+}
+
+// Mock is a chapa.API whose responses are scripted with On. The zero value
+// is ready to use.
+type Mock struct {
+	mu    sync.Mutex
+	stubs []*Stub
+	calls []Call
+}
+
+// New returns an empty Mock.
+func New() *Mock {
+	return &Mock{}
+}
+
+// On adds a stub for method. Stubs are matched in the order they were added.
+func (m *Mock) On(method Method) *Stub {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stub := &Stub{method: method}
+	m.stubs = append(m.stubs, stub)
+	return stub
+}
+
+// Reset removes every stub and recorded call.
+func (m *Mock) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.stubs = nil
+	m.calls = nil
+}
+
+// Calls returns the recorded calls to method, or every recorded call if
+// method is empty.
+func (m *Mock) Calls(method Method) []Call {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var calls []Call
+	for _, call := range m.calls {
+		if method == "" || call.Method == method {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+// AssertCalled checks that method was called, with args if any are given.
+func (m *Mock) AssertCalled(t TestingT, method Method, args ...interface{}) bool {
+	for _, call := range m.Calls(method) {
+		if len(args) == 0 || argsMatch(args, call.Args) {
+			return true
+		}
+	}
+
+	if len(args) == 0 {
+		t.Errorf("chapamock: expected a call to %s", method)
+	} else {
+		t.Errorf("chapamock: expected a call to %s with %v, got %v", method, args, m.Calls(method))
+	}
+	return false
+}
+
+// AssertNotCalled checks that method was not called.
+func (m *Mock) AssertNotCalled(t TestingT, method Method) bool {
+	if calls := m.Calls(method); len(calls) > 0 {
+		t.Errorf("chapamock: expected no call to %s, got %d", method, len(calls))
+		return false
+	}
+	return true
+}
+
+// AssertNumberOfCalls checks that method was called n times.
+func (m *Mock) AssertNumberOfCalls(t TestingT, method Method, n int) bool {
+	if calls := m.Calls(method); len(calls) != n {
+		t.Errorf("chapamock: expected %d calls to %s, got %d", n, method, len(calls))
+		return false
+	}
+	return true
+}
+
+// AssertExpectations checks that every stub was used, and that stubs limited
+// with Times were used exactly that many times.
+func (m *Mock) AssertExpectations(t TestingT) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ok := true
+	for _, stub := range m.stubs {
+		switch {
+		case stub.times > 0 && stub.calls != stub.times:
+			t.Errorf("chapamock: expected %d calls to %s%v, got %d", stub.times, stub.method, stubArgs(stub), stub.calls)
+			ok = false
+		case stub.calls == 0:
+			t.Errorf("chapamock: expected a call to %s%v", stub.method, stubArgs(stub))
+			ok = false
+		}
+	}
+	return ok
+}
+
+// call records a call and returns the result of the first stub matching it.
+func (m *Mock) call(ctx context.Context, method Method, args ...interface{}) (interface{}, error) {
+	call := Call{Method: method, Ctx: ctx, Args: args}
+
+	m.mu.Lock()
+	m.calls = append(m.calls, call)
+
+	var stub *Stub
+	for _, candidate := range m.stubs {
+		if candidate.matches(method, args) {
+			stub = candidate
+			stub.calls++
+			break
+		}
+	}
+	m.mu.Unlock()
+
+	if stub == nil {
+		return nil, fmt.Errorf("%w to %s%v", ErrUnexpectedCall, method, args)
+	}
+
+	if stub.run != nil {
+		stub.run(call)
+	}
+	return stub.response, stub.err
+}
+
+// result asserts that response, as scripted for method, is a *T.
+func result[T any](method Method, response interface{}, err error) (*T, error) {
+	if response == nil {
+		return nil, err
+	}
+
+	typed, ok := response.(*T)
+	if !ok {
+		panic(fmt.Sprintf("chapamock: %s must return %T, not %T", method, typed, response))
+	}
+	return typed, err
+}
+
+func argsMatch(expected, actual []interface{}) bool {
+	if len(expected) != len(actual) {
+		return false
+	}
+	for i := range expected {
+		if expected[i] == Anything {
+			continue
+		}
+		if !reflect.DeepEqual(expected[i], actual[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func stubArgs(stub *Stub) []interface{} {
+	if stub.args == nil {
+		return []interface{}{}
+	}
+	return stub.args
+}
@@ -0,0 +1,144 @@
+package chapamock
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	chapa "github.com/Chapa-Et/chapa-go"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+// recorder is a TestingT that keeps the failures it is given.
+type recorder struct {
+	failures []string
+}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.failures = append(r.failures, fmt.Sprintf(format, args...))
+}
+
+func TestMock(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("returns scripted responses in order", func(t *testing.T) {
+		mock := New()
+		mock.On(Verify).Return(&chapa.VerifyResponse{Status: "pending"}, nil).Once()
+		mock.On(Verify).Return(&chapa.VerifyResponse{Status: "success"}, nil)
+
+		response, err := mock.Verify("tx-1")
+		assert.NoError(t, err)
+		assert.Equal(t, "pending", response.Status)
+
+		for i := 0; i < 2; i++ {
+			response, err = mock.VerifyWithContext(ctx, "tx-1")
+			assert.NoError(t, err)
+			assert.Equal(t, "success", response.Status)
+		}
+
+		assert.True(t, mock.AssertNumberOfCalls(t, Verify, 3))
+		assert.True(t, mock.AssertExpectations(t))
+	})
+
+	t.Run("matches stubs by arguments", func(t *testing.T) {
+		mock := New()
+		mock.On(VerifyTransfer).WithArgs("ref-1").Return(&chapa.VerifyTransferResponse{Status: "success"}, nil)
+		mock.On(VerifyTransfer).WithArgs(Anything).Return(nil, chapa.ErrNotFound)
+
+		response, err := mock.VerifyTransfer(ctx, "ref-1")
+		assert.NoError(t, err)
+		assert.Equal(t, "success", response.Status)
+
+		response, err = mock.VerifyTransfer(ctx, "ref-2")
+		assert.ErrorIs(t, err, chapa.ErrNotFound)
+		assert.Nil(t, response)
+
+		assert.True(t, mock.AssertCalled(t, VerifyTransfer, "ref-2"))
+	})
+
+	t.Run("fails unexpected calls", func(t *testing.T) {
+		mock := New()
+
+		_, err := mock.GetBanks()
+		assert.ErrorIs(t, err, ErrUnexpectedCall)
+		assert.Len(t, mock.Calls(GetBanks), 1)
+	})
+
+	t.Run("records calls", func(t *testing.T) {
+		mock := New()
+		mock.On(GetBulkTransfer).Return(&chapa.BulkTransferResult{ID: 7}, nil)
+
+		var seen []Call
+		mock.On(TransferToBank).Run(func(call Call) { seen = append(seen, call) }).Return(&chapa.BankTransferResponse{}, nil)
+
+		request := &chapa.BankTransfer{Reference: "ref-1"}
+		_, _ = mock.TransferToBank(request)
+		_, _ = mock.GetBulkTransfer(ctx, 7)
+
+		calls := mock.Calls("")
+		assert.Len(t, calls, 2)
+		assert.Equal(t, TransferToBank, calls[0].Method)
+		assert.Equal(t, []interface{}{request}, calls[0].Args)
+		assert.Equal(t, []interface{}{7}, calls[1].Args)
+		assert.Len(t, seen, 1)
+
+		mock.Reset()
+		assert.Empty(t, mock.Calls(""))
+	})
+
+	t.Run("reports failed assertions", func(t *testing.T) {
+		mock := New()
+		mock.On(GetBanks).Return(&chapa.BanksResponse{}, nil).Times(2)
+		mock.On(ListTransfers).Return(&chapa.TransfersResponse{}, nil)
+		_, _ = mock.GetBanks()
+
+		r := &recorder{}
+		assert.False(t, mock.AssertCalled(r, Verify))
+		assert.False(t, mock.AssertNotCalled(r, GetBanks))
+		assert.False(t, mock.AssertNumberOfCalls(r, GetBanks, 2))
+		assert.False(t, mock.AssertExpectations(r))
+		assert.Len(t, r.failures, 5)
+	})
+
+	t.Run("iterates over scripted pages", func(t *testing.T) {
+		mock := New()
+		mock.On(ListTransactions).Return(&chapa.TransactionsResponse{Data: chapa.TransactionList{
+			Transactions: []chapa.Transaction{{TransID: "tx-1"}},
+			Pagination:   chapa.Pagination{NextPageURL: "next"},
+		}}, nil).Once()
+		mock.On(ListTransactions).Return(&chapa.TransactionsResponse{Data: chapa.TransactionList{
+			Transactions: []chapa.Transaction{{TransID: "tx-2"}},
+		}}, nil).Once()
+
+		var ids []string
+		it := mock.Transactions(ctx, nil)
+		for it.Next() {
+			ids = append(ids, it.Transaction().TransID)
+		}
+		assert.NoError(t, it.Err())
+		assert.Equal(t, []string{"tx-1", "tx-2"}, ids)
+		assert.Equal(t, 2, mock.Calls(ListTransactions)[1].Args[0].(*chapa.TransactionFilter).Page)
+	})
+
+	t.Run("panics on a response of the wrong type", func(t *testing.T) {
+		mock := New()
+		mock.On(GetBanks).Return(&chapa.VerifyResponse{}, nil)
+
+		assert.Panics(t, func() { _, _ = mock.GetBanks() })
+	})
+
+	t.Run("stands in for the API in services", func(t *testing.T) {
+		mock := New()
+		mock.On(PaymentRequest).Return(&chapa.PaymentResponse{Status: "success"}, nil)
+
+		service := chapa.NewExamplePaymentService(mock)
+		transaction, err := service.Checkout(ctx, 1032, chapa.CheckoutForm{Amount: decimal.NewFromInt(10), Currency: "ETB"})
+		assert.NoError(t, err)
+		assert.Equal(t, chapa.PendingTransactionStatus, transaction.Status)
+
+		request := mock.Calls(PaymentRequest)[0].Args[0].(*chapa.PaymentRequest)
+		assert.Equal(t, transaction.TransID, request.TransactionRef)
+		assert.Equal(t, chapa.Customers[1].Email, request.Email)
+	})
+}
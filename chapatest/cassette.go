@@ -0,0 +1,261 @@
+package chapatest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+)
+
+// redacted replaces the credentials of recorded requests.
+const redacted = "[REDACTED]"
+
+// ErrInteractionNotFound is returned by a Replayer for requests that are not
+// in its cassette, or whose recorded interactions were all served.
+var ErrInteractionNotFound = errors.New("chapatest: no recorded interaction for request")
+
+// Interaction is a request and its response, one line of a cassette file.
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+// RecordedRequest is a request as stored in a cassette. The bearer key of
+// the Authorization header is redacted.
+type RecordedRequest struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body,omitempty"`
+}
+
+// RecordedResponse is a response as stored in a cassette.
+type RecordedResponse struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body,omitempty"`
+}
+
+// Recorder is an http.RoundTripper that sends requests through another
+// transport and writes every exchange to a JSONL cassette file.
+type Recorder struct {
+	transport http.RoundTripper
+
+	mu   sync.Mutex
+	file *os.File
+}
+
+// NewRecorder creates or truncates the cassette at path and records the
+// exchanges of transport into it. A nil transport uses http.DefaultTransport.
+// The caller must Close the Recorder to flush the cassette.
+func NewRecorder(path string, transport http.RoundTripper) (*Recorder, error) {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Recorder{transport: transport, file: file}, nil
+}
+
+// RoundTrip sends req and records it with its response. Requests that fail
+// without a response are not recorded.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readBody(req.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	// a RoundTripper must not modify req, so the body goes out on a copy
+	out := req.Clone(req.Context())
+	if body != nil {
+		out.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	resp, err := r.transport.RoundTrip(out)
+	if err != nil {
+		return nil, err
+	}
+	resp.Request = req
+
+	respBody, err := readBody(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	line, err := json.Marshal(Interaction{
+		Request: RecordedRequest{
+			Method: req.Method,
+			URL:    req.URL.String(),
+			Header: redactHeader(req.Header),
+			Body:   string(body),
+		},
+		Response: RecordedResponse{
+			StatusCode: resp.StatusCode,
+			Header:     resp.Header,
+			Body:       string(respBody),
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, err = r.file.Write(append(line, '\n')); err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// Close closes the cassette file.
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.file.Close()
+}
+
+// MatchFunc reports whether a request with body matches a recorded request.
+type MatchFunc func(req *http.Request, body []byte, recorded *RecordedRequest) bool
+
+// ReplayOption configures a Replayer created with NewReplayer.
+type ReplayOption func(*Replayer)
+
+// WithMatcher replaces the way requests are matched to recorded ones.
+func WithMatcher(match MatchFunc) ReplayOption {
+	return func(r *Replayer) {
+		r.match = match
+	}
+}
+
+// Replayer is an http.RoundTripper that answers requests from a cassette
+// without touching the network. Each interaction is served once, in the
+// order it was recorded.
+type Replayer struct {
+	match MatchFunc
+
+	mu           sync.Mutex
+	interactions []Interaction
+	served       []bool
+}
+
+// NewReplayer loads the cassette at path.
+func NewReplayer(path string, opts ...ReplayOption) (*Replayer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Replayer{match: MatchRequest}
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	for i, line := range bytes.Split(data, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		var interaction Interaction
+		if err = json.Unmarshal(line, &interaction); err != nil {
+			return nil, fmt.Errorf("chapatest: cassette %s line %d: %w", path, i+1, err)
+		}
+		r.interactions = append(r.interactions, interaction)
+	}
+	r.served = make([]bool, len(r.interactions))
+
+	return r, nil
+}
+
+// RoundTrip answers req with the first unserved interaction matching it.
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readBody(req.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.interactions {
+		if r.served[i] || !r.match(req, body, &r.interactions[i].Request) {
+			continue
+		}
+		r.served[i] = true
+
+		recorded := r.interactions[i].Response
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", recorded.StatusCode, http.StatusText(recorded.StatusCode)),
+			StatusCode:    recorded.StatusCode,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        recorded.Header.Clone(),
+			Body:          io.NopCloser(strings.NewReader(recorded.Body)),
+			ContentLength: int64(len(recorded.Body)),
+			Request:       req,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("%w: %s %s", ErrInteractionNotFound, req.Method, req.URL)
+}
+
+// Unserved returns the recorded interactions that have not been served yet.
+func (r *Replayer) Unserved() []Interaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var unserved []Interaction
+	for i, served := range r.served {
+		if !served {
+			unserved = append(unserved, r.interactions[i])
+		}
+	}
+	return unserved
+}
+
+// MatchRequest is the default MatchFunc. It matches the method, the path and
+// query of the URL, and the body. The host is ignored, so a cassette recorded
+// against one base URL replays against another.
+func MatchRequest(req *http.Request, body []byte, recorded *RecordedRequest) bool {
+	if req.Method != recorded.Method || string(body) != recorded.Body {
+		return false
+	}
+
+	recordedURL, err := url.Parse(recorded.URL)
+	if err != nil {
+		return false
+	}
+	return req.URL.RequestURI() == recordedURL.RequestURI()
+}
+
+// readBody reads body fully and closes it.
+func readBody(body io.ReadCloser) ([]byte, error) {
+	if body == nil || body == http.NoBody {
+		return nil, nil
+	}
+
+	defer body.Close()
+	return io.ReadAll(body)
+}
+
+func redactHeader(header http.Header) http.Header {
+	header = header.Clone()
+	if header.Get("Authorization") != "" {
+		header.Set("Authorization", "Bearer "+redacted)
+	}
+	return header
+}
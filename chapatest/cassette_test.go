@@ -0,0 +1,128 @@
+package chapatest
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	chapa "github.com/Chapa-Et/chapa-go"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCassette(t *testing.T) {
+	request := &chapa.PaymentRequest{
+		Amount:         decimal.NewFromInt(100),
+		Currency:       "ETB",
+		FirstName:      "Abebe",
+		LastName:       "Bikila",
+		Email:          "abebe@bikila.et",
+		CallbackURL:    "https://example.com/callback",
+		TransactionRef: "tx-1",
+	}
+
+	// record runs a payment and a verify against a fake server through a
+	// Recorder and returns the cassette path.
+	record := func(t *testing.T) string {
+		path := filepath.Join(t.TempDir(), "cassette.jsonl")
+
+		server := NewServer()
+		defer server.Close()
+
+		recorder, err := NewRecorder(path, nil)
+		assert.NoError(t, err)
+
+		paymentProvider := server.Client(chapa.WithHTTPClient(&http.Client{Transport: recorder}))
+		_, err = paymentProvider.PaymentRequest(request)
+		assert.NoError(t, err)
+		_, err = paymentProvider.Verify("unknown")
+		assert.ErrorIs(t, err, chapa.ErrNotFound)
+
+		assert.NoError(t, recorder.Close())
+		return path
+	}
+
+	t.Run("records with the key redacted", func(t *testing.T) {
+		data, err := os.ReadFile(record(t))
+		assert.NoError(t, err)
+
+		assert.Equal(t, 2, bytes.Count(data, []byte("\n")))
+		assert.NotContains(t, string(data), DefaultAPIKey)
+		assert.Contains(t, string(data), "Bearer [REDACTED]")
+	})
+
+	t.Run("leaves the recorded request untouched", func(t *testing.T) {
+		server := NewServer()
+		defer server.Close()
+
+		recorder, err := NewRecorder(filepath.Join(t.TempDir(), "cassette.jsonl"), nil)
+		assert.NoError(t, err)
+		defer recorder.Close()
+
+		body := io.NopCloser(strings.NewReader(`{"tx_ref":"tx-1"}`))
+		req, err := http.NewRequest(http.MethodPost, server.URL+"/v1/transaction/initialize", body)
+		assert.NoError(t, err)
+
+		resp, err := recorder.RoundTrip(req)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+		assert.Same(t, req, resp.Request)
+		assert.True(t, req.Body == body)
+	})
+
+	t.Run("replays recorded interactions", func(t *testing.T) {
+		replayer, err := NewReplayer(record(t))
+		assert.NoError(t, err)
+
+		paymentProvider := chapa.NewClient("key",
+			chapa.WithBaseURL("https://api.chapa.invalid"),
+			chapa.WithHTTPClient(&http.Client{Transport: replayer}))
+
+		response, err := paymentProvider.PaymentRequest(request)
+		assert.NoError(t, err)
+		assert.Equal(t, "success", response.Status)
+		assert.NotEmpty(t, response.Data.CheckoutURL)
+
+		_, err = paymentProvider.Verify("unknown")
+		assert.ErrorIs(t, err, chapa.ErrNotFound)
+		assert.Empty(t, replayer.Unserved())
+
+		_, err = paymentProvider.Verify("unknown")
+		assert.ErrorIs(t, err, ErrInteractionNotFound)
+	})
+
+	t.Run("matches with a custom matcher", func(t *testing.T) {
+		path := record(t)
+
+		replayer, err := NewReplayer(path)
+		assert.NoError(t, err)
+
+		other := *request
+		other.TransactionRef = "tx-2"
+		paymentProvider := chapa.NewClient("key", chapa.WithHTTPClient(&http.Client{Transport: replayer}))
+		_, err = paymentProvider.PaymentRequest(&other)
+		assert.ErrorIs(t, err, ErrInteractionNotFound)
+
+		byMethod := func(req *http.Request, _ []byte, recorded *RecordedRequest) bool {
+			return req.Method == recorded.Method
+		}
+		replayer, err = NewReplayer(path, WithMatcher(byMethod))
+		assert.NoError(t, err)
+
+		paymentProvider = chapa.NewClient("key", chapa.WithHTTPClient(&http.Client{Transport: replayer}))
+		_, err = paymentProvider.PaymentRequest(&other)
+		assert.NoError(t, err)
+	})
+
+	t.Run("rejects malformed cassettes", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "cassette.jsonl")
+		assert.NoError(t, os.WriteFile(path, []byte("{}\nnot json\n"), 0o644))
+
+		_, err := NewReplayer(path)
+		assert.ErrorContains(t, err, "line 2")
+	})
+}
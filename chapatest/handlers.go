@@ -0,0 +1,761 @@
+package chapatest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	chapa "github.com/Chapa-Et/chapa-go"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	checkoutPath = "/checkout/payment/"
+	advancePath  = "/simulator/advance"
+)
+
+func (s *Server) route(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.tick()
+	s.mu.Unlock()
+
+	path := endpointPath(r.URL.Path)
+
+	switch {
+	case r.Method == http.MethodPost && path == "/transaction/initialize":
+		s.initialize(w, r)
+	case r.Method == http.MethodGet && strings.HasPrefix(path, "/transaction/verify/"):
+		s.verify(w, strings.TrimPrefix(path, "/transaction/verify/"))
+	case r.Method == http.MethodGet && strings.HasPrefix(path, "/transaction/events/"):
+		s.transactionLogs(w, strings.TrimPrefix(path, "/transaction/events/"))
+	case r.Method == http.MethodPost && path == "/charges":
+		s.directCharge(w, r)
+	case r.Method == http.MethodPost && path == "/validate":
+		s.authorizeCharge(w, r)
+	case r.Method == http.MethodPost && strings.HasPrefix(path, "/refund/"):
+		s.refund(w, r, strings.TrimPrefix(path, "/refund/"))
+	case r.Method == http.MethodGet && path == "/transactions":
+		s.listTransactions(w, r)
+	case r.Method == http.MethodGet && path == "/banks":
+		s.listBanks(w)
+	case r.Method == http.MethodPost && path == "/transfers":
+		s.transfer(w, r)
+	case r.Method == http.MethodGet && path == "/transfers":
+		s.listTransfers(w, r)
+	case r.Method == http.MethodGet && strings.HasPrefix(path, "/transfers/verify/"):
+		s.verifyTransfer(w, strings.TrimPrefix(path, "/transfers/verify/"))
+	case r.Method == http.MethodPost && path == "/bulk-transfers":
+		s.bulkTransfer(w, r)
+	case r.Method == http.MethodPost && path == "/subaccount":
+		s.createSubaccount(w, r)
+	case r.Method == http.MethodGet && path == "/subaccount":
+		s.listSubaccounts(w)
+	default:
+		writeError(w, http.StatusNotFound, "Not found")
+	}
+}
+
+// checkout pays the transaction behind a checkout URL, as a customer would.
+func (s *Server) checkout(w http.ResponseWriter, r *http.Request) {
+	txRef := strings.TrimPrefix(r.URL.Path, checkoutPath)
+
+	s.mu.Lock()
+	transaction, ok := s.transactions[txRef]
+	if ok {
+		s.logTransaction(txRef, "checkout", "Checkout page opened", s.now())
+		s.setTransactionStatus(transaction, chapa.SuccessTransactionStatus, s.now())
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		http.Error(w, "Invalid transaction reference", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_, _ = w.Write([]byte("Payment completed"))
+}
+
+// advance moves the clock forward by the duration in the "by" parameter, for
+// driving scenarios from outside the process.
+func (s *Server) advance(w http.ResponseWriter, r *http.Request) {
+	by, err := time.ParseDuration(r.FormValue("by"))
+	if err != nil || by < 0 {
+		writeError(w, http.StatusBadRequest, "Invalid duration")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"message": "Clock advanced",
+		"status":  "success",
+		"data":    map[string]time.Time{"now": s.Advance(by)},
+	})
+}
+
+func (s *Server) initialize(w http.ResponseWriter, r *http.Request) {
+	var request chapa.PaymentRequest
+	if !decode(w, r, &request) {
+		return
+	}
+	if err := request.Validate(); err != nil {
+		writeValidationError(w, err)
+		return
+	}
+	if request.Currency != string(chapa.ETB) && request.Currency != string(chapa.USD) {
+		writeError(w, http.StatusBadRequest, map[string][]string{"currency": {"The selected currency is invalid."}})
+		return
+	}
+	if !request.Amount.IsPositive() {
+		writeError(w, http.StatusBadRequest, map[string][]string{"amount": {"The amount must be greater than 0."}})
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, split := range request.Subaccounts {
+		if !s.hasSubaccount(split.ID) {
+			writeError(w, http.StatusBadRequest, "Invalid subaccount "+split.ID)
+			return
+		}
+	}
+
+	now := s.now()
+	transaction := &chapa.VerifyData{
+		FirstName:     request.FirstName,
+		LastName:      request.LastName,
+		Email:         request.Email,
+		Currency:      request.Currency,
+		Amount:        request.Amount,
+		Charge:        charge(request.Amount),
+		Mode:          "test",
+		Type:          "API",
+		Status:        chapa.PendingTransactionStatus,
+		Reference:     s.nextReference("AP"),
+		TxRef:         request.TransactionRef,
+		Customization: customization(request.Customization),
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	if !s.addTransaction(w, transaction, "Payment link created") {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"message": "Hosted Link",
+		"status":  "success",
+		"data": map[string]string{
+			"checkout_url": s.URL + checkoutPath + url.PathEscape(request.TransactionRef),
+		},
+	})
+}
+
+// addTransaction stores a new transaction, or answers with a 400 if its
+// tx_ref was used or a scenario rejects it. s.mu must be held.
+func (s *Server) addTransaction(w http.ResponseWriter, transaction *chapa.VerifyData, event string) bool {
+	if _, ok := s.transactions[transaction.TxRef]; ok {
+		writeError(w, http.StatusBadRequest, "Transaction reference has been used before")
+		return false
+	}
+	if scenario := s.scenario(KindPayment, transaction.TxRef, transaction.Amount); scenario != nil && scenario.Reject != "" {
+		writeError(w, http.StatusBadRequest, scenario.Reject)
+		return false
+	}
+
+	s.transactions[transaction.TxRef] = transaction
+	s.txOrder = append(s.txOrder, transaction.TxRef)
+	s.logTransaction(transaction.TxRef, "log", event, transaction.CreatedAt)
+	s.follow(KindPayment, transaction.TxRef, transaction.Amount)
+	return true
+}
+
+// directCharge starts a pending wallet charge. telebirr and CBE Birr charges
+// are confirmed on the customer's phone, which tests do with Complete; M-Pesa
+// and AwashBirr charges are authorized with TestOTP.
+func (s *Server) directCharge(w http.ResponseWriter, r *http.Request) {
+	method := chapa.PaymentMethod(r.URL.Query().Get("type"))
+
+	authType := chapa.USSDAuth
+	switch method {
+	case chapa.Telebirr, chapa.CBEBirr:
+	case chapa.Mpesa, chapa.AwashBirr:
+		authType = chapa.OTPAuth
+	default:
+		writeError(w, http.StatusBadRequest, "Invalid payment method")
+		return
+	}
+
+	var request chapa.DirectChargeRequest
+	if !decode(w, r, &request) {
+		return
+	}
+	if err := request.Validate(); err != nil {
+		writeValidationError(w, err)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	transaction := &chapa.VerifyData{
+		FirstName: request.FirstName,
+		LastName:  request.LastName,
+		Email:     request.Email,
+		Currency:  request.Currency,
+		Amount:    request.Amount,
+		Charge:    charge(request.Amount),
+		Mode:      "test",
+		Method:    string(method),
+		Type:      "API",
+		Status:    chapa.PendingTransactionStatus,
+		Reference: s.nextReference("DC"),
+		TxRef:     request.TransactionRef,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if !s.addTransaction(w, transaction, "Direct charge sent to "+string(method)) {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"message": "Charge initiated",
+		"status":  "success",
+		"data": chapa.DirectChargeData{
+			AuthType:  authType,
+			Reference: transaction.Reference,
+		},
+	})
+}
+
+func (s *Server) authorizeCharge(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Reference string `json:"reference"`
+		OTP       string `json:"otp"`
+	}
+	if !decode(w, r, &request) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var transaction *chapa.VerifyData
+	for _, candidate := range s.transactions {
+		if candidate.Reference == request.Reference {
+			transaction = candidate
+		}
+	}
+
+	switch {
+	case transaction == nil:
+		writeError(w, http.StatusNotFound, "Invalid charge reference")
+		return
+	case transaction.Status != chapa.PendingTransactionStatus:
+		writeError(w, http.StatusBadRequest, "Charge is no longer pending")
+		return
+	case request.OTP != TestOTP:
+		writeError(w, http.StatusBadRequest, "Invalid OTP")
+		return
+	}
+
+	s.setTransactionStatus(transaction, chapa.SuccessTransactionStatus, s.now())
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"message": "Payment completed",
+		"status":  "success",
+		"data": map[string]interface{}{
+			"reference": transaction.Reference,
+			"status":    transaction.Status,
+		},
+	})
+}
+
+func (s *Server) verify(w http.ResponseWriter, txRef string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	transaction, ok := s.transactions[txRef]
+	if !ok {
+		writeError(w, http.StatusNotFound, "Invalid transaction reference")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"message": "Payment details fetched successfully",
+		"status":  "success",
+		"data":    transaction,
+	})
+}
+
+func (s *Server) transactionLogs(w http.ResponseWriter, txRef string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.transactions[txRef]; !ok {
+		writeError(w, http.StatusNotFound, "Invalid transaction reference")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"message": "Transaction events fetched",
+		"status":  "success",
+		"data":    s.txLogs[txRef],
+	})
+}
+
+func (s *Server) refund(w http.ResponseWriter, r *http.Request, txRef string) {
+	var request chapa.RefundRequest
+	if !decode(w, r, &request) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	transaction, ok := s.transactions[txRef]
+	if !ok {
+		writeError(w, http.StatusNotFound, "Invalid transaction reference")
+		return
+	}
+	if transaction.Status != chapa.SuccessTransactionStatus {
+		writeError(w, http.StatusBadRequest, "Only successful transactions can be refunded")
+		return
+	}
+	if request.Reference != "" && s.refundRefs[request.Reference] {
+		writeError(w, http.StatusBadRequest, "Refund reference has been used before")
+		return
+	}
+
+	remaining := transaction.Amount.Sub(s.refunds[txRef])
+	amount := request.Amount
+	if amount.IsZero() {
+		amount = remaining
+	}
+	if !amount.IsPositive() || amount.GreaterThan(remaining) {
+		writeError(w, http.StatusBadRequest, "Refund amount exceeds the refundable balance")
+		return
+	}
+
+	now := s.now()
+	s.refunds[txRef] = s.refunds[txRef].Add(amount)
+	s.refundRefs[request.Reference] = true
+	s.logTransaction(txRef, "refund", "Refunded "+amount.StringFixed(2)+" "+transaction.Currency, now)
+	if s.refunds[txRef].Equal(transaction.Amount) {
+		s.setTransactionStatus(transaction, chapa.RefundedTransactionStatus, now)
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"message": "Refund processed successfully",
+		"status":  "success",
+		"data": chapa.Refund{
+			ChapaReference: s.nextReference("RF"),
+			Reference:      request.Reference,
+			TxRef:          txRef,
+			Amount:         amount,
+			Currency:       transaction.Currency,
+			Status:         "success",
+			CreatedAt:      now,
+		},
+	})
+}
+
+func (s *Server) listTransactions(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	from, _ := time.Parse("2006-01-02", query.Get("from"))
+	to, _ := time.Parse("2006-01-02", query.Get("to"))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	transactions := make([]chapa.Transaction, 0, len(s.txOrder))
+	for i := len(s.txOrder) - 1; i >= 0; i-- {
+		transaction := s.transactions[s.txOrder[i]]
+		if status := query.Get("status"); status != "" && string(transaction.Status) != status {
+			continue
+		}
+		if currency := query.Get("currency"); currency != "" && !strings.EqualFold(transaction.Currency, currency) {
+			continue
+		}
+		if method := query.Get("payment_method"); method != "" && transaction.Method != method {
+			continue
+		}
+		if reference := query.Get("reference"); reference != "" && transaction.TxRef != reference && transaction.Reference != reference {
+			continue
+		}
+		if customer := query.Get("customer"); customer != "" && !strings.EqualFold(transaction.Email, customer) {
+			continue
+		}
+		if !from.IsZero() && transaction.CreatedAt.Before(from) {
+			continue
+		}
+		if !to.IsZero() && !transaction.CreatedAt.Before(to.AddDate(0, 0, 1)) {
+			continue
+		}
+		transactions = append(transactions, toTransaction(transaction))
+	}
+
+	start, end, pagination := s.paginate(r, len(transactions))
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"message": "Transactions retrieved successfully",
+		"status":  "success",
+		"data": chapa.TransactionList{
+			Transactions: transactions[start:end],
+			Pagination:   pagination,
+		},
+	})
+}
+
+func (s *Server) listBanks(w http.ResponseWriter) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"message": "Banks retrieved",
+		"data":    s.banks,
+	})
+}
+
+func (s *Server) transfer(w http.ResponseWriter, r *http.Request) {
+	var request chapa.BankTransfer
+	if !decode(w, r, &request) {
+		return
+	}
+	if err := request.Validate(); err != nil {
+		writeValidationError(w, err)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	transfer, status, message := s.newTransfer(request.AccountName, request.AccountNumber, request.Currency,
+		decimal.NewFromFloat(request.Amount), request.Reference, request.BankCode)
+	if transfer == nil {
+		writeError(w, status, message)
+		return
+	}
+	s.addTransfer(transfer)
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"message": "Transfer queued successfully",
+		"status":  "success",
+		"data":    transfer.ChapaReference,
+	})
+}
+
+func (s *Server) listTransfers(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	from, _ := time.Parse("2006-01-02", query.Get("from"))
+	to, _ := time.Parse("2006-01-02", query.Get("to"))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	references := s.transferOrder
+	if batchID := query.Get("batch_id"); batchID != "" {
+		id, _ := strconv.Atoi(batchID)
+		references = s.batches[id]
+	}
+
+	transfers := make([]chapa.Transfer, 0, len(references))
+	for i := len(references) - 1; i >= 0; i-- {
+		transfer := s.transfers[references[i]]
+		if status := query.Get("status"); status != "" && string(transfer.Status) != status {
+			continue
+		}
+		if !from.IsZero() && transfer.CreatedAt.Before(from) {
+			continue
+		}
+		if !to.IsZero() && !transfer.CreatedAt.Before(to.AddDate(0, 0, 1)) {
+			continue
+		}
+		transfers = append(transfers, *transfer)
+	}
+
+	start, end, pagination := s.paginate(r, len(transfers))
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"message": "Transfers retrieved",
+		"status":  "success",
+		"meta":    pagination,
+		"data":    transfers[start:end],
+	})
+}
+
+func (s *Server) verifyTransfer(w http.ResponseWriter, reference string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	transfer, ok := s.transfers[reference]
+	if !ok {
+		writeError(w, http.StatusNotFound, "Invalid transfer reference")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"message": "Transfer details",
+		"status":  "success",
+		"data":    transfer,
+	})
+}
+
+func (s *Server) bulkTransfer(w http.ResponseWriter, r *http.Request) {
+	var request chapa.BulkTransferRequest
+	if !decode(w, r, &request) {
+		return
+	}
+	if err := request.Validate(); err != nil {
+		writeValidationError(w, err)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	transfers := make([]*chapa.Transfer, 0, len(request.BulkData))
+	seen := make(map[string]bool)
+	for _, item := range request.BulkData {
+		transfer, status, message := s.newTransfer(item.AccountName, item.AccountNumber, request.Currency,
+			decimal.NewFromInt(item.Amount), item.Reference, item.BankCode)
+		if transfer == nil || seen[item.Reference] {
+			if transfer != nil {
+				status, message = http.StatusBadRequest, "Transfer reference has been used before"
+			}
+			writeError(w, status, message)
+			return
+		}
+		seen[item.Reference] = true
+		transfers = append(transfers, transfer)
+	}
+
+	batchID := len(s.batches) + 1
+	for _, transfer := range transfers {
+		s.addTransfer(transfer)
+		s.batches[batchID] = append(s.batches[batchID], transfer.Reference)
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"message": "Bulk transfer queued successfully",
+		"status":  "success",
+		"data": chapa.BulkTransferResponseData{
+			ID:        batchID,
+			CreatedAt: s.now().UTC().Format(time.RFC3339),
+		},
+	})
+}
+
+func (s *Server) createSubaccount(w http.ResponseWriter, r *http.Request) {
+	var request chapa.Subaccount
+	if !decode(w, r, &request) {
+		return
+	}
+	if err := request.Validate(); err != nil {
+		writeValidationError(w, err)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.bank(request.BankCode); !ok {
+		writeError(w, http.StatusBadRequest, "Invalid bank code")
+		return
+	}
+
+	request.ID = s.nextReference("SA")
+	s.subaccounts = append(s.subaccounts, request)
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"message": "Subaccount created successfully",
+		"status":  "success",
+		"data":    map[string]string{"subaccount_id": request.ID},
+	})
+}
+
+func (s *Server) listSubaccounts(w http.ResponseWriter) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"message": "Subaccounts retrieved",
+		"status":  "success",
+		"data":    s.subaccounts,
+	})
+}
+
+// hasSubaccount reports whether a subaccount with id exists. s.mu must be held.
+func (s *Server) hasSubaccount(id string) bool {
+	for _, subaccount := range s.subaccounts {
+		if subaccount.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// newTransfer builds a pending transfer, or returns the status and message
+// Chapa answers with when it cannot be made. s.mu must be held.
+func (s *Server) newTransfer(accountName, accountNumber, currency string, amount decimal.Decimal, reference, bankCode string) (*chapa.Transfer, int, interface{}) {
+	if _, ok := s.transfers[reference]; ok {
+		return nil, http.StatusBadRequest, "Transfer reference has been used before"
+	}
+
+	bank, ok := s.bank(bankCode)
+	if !ok {
+		return nil, http.StatusBadRequest, "Invalid bank code"
+	}
+	if bank.AcctLength > 0 && int64(len(accountNumber)) != bank.AcctLength {
+		return nil, http.StatusBadRequest, map[string][]string{
+			"account_number": {fmt.Sprintf("The account number must be %d digits for %s.", bank.AcctLength, bank.Name)},
+		}
+	}
+	if scenario := s.scenario(KindTransfer, reference, amount); scenario != nil && scenario.Reject != "" {
+		return nil, http.StatusBadRequest, scenario.Reject
+	}
+
+	method := "bank"
+	if bank.IsMobileMoney == 1 {
+		method = "mobile_money"
+	}
+
+	now := s.now()
+	return &chapa.Transfer{
+		AccountName:    accountName,
+		AccountNumber:  accountNumber,
+		Currency:       currency,
+		Amount:         amount,
+		Charge:         charge(amount),
+		Mode:           "test",
+		TransferMethod: method,
+		ChapaReference: s.nextReference("TR"),
+		BankCode:       bankCode,
+		BankName:       bank.Name,
+		Status:         chapa.PendingTransferStatus,
+		Reference:      reference,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}, 0, nil
+}
+
+// addTransfer stores transfer. s.mu must be held.
+func (s *Server) addTransfer(transfer *chapa.Transfer) {
+	s.transfers[transfer.Reference] = transfer
+	s.transferOrder = append(s.transferOrder, transfer.Reference)
+	s.follow(KindTransfer, transfer.Reference, transfer.Amount)
+}
+
+// bank returns the bank with code. s.mu must be held.
+func (s *Server) bank(code string) (chapa.Bank, bool) {
+	for _, bank := range s.banks {
+		if strconv.FormatInt(bank.ID, 10) == code {
+			return bank, true
+		}
+	}
+	return chapa.Bank{}, false
+}
+
+// paginate returns the bounds of the requested page of total items and its
+// pagination links, keeping the other query parameters of r.
+func (s *Server) paginate(r *http.Request, total int) (int, int, chapa.Pagination) {
+	query := r.URL.Query()
+
+	page, _ := strconv.Atoi(query.Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	perPage, _ := strconv.Atoi(query.Get("per_page"))
+	if perPage < 1 {
+		perPage = 10
+	}
+
+	pageURL := func(page int) string {
+		query.Set("page", strconv.Itoa(page))
+		query.Set("per_page", strconv.Itoa(perPage))
+		return s.URL + r.URL.Path + "?" + query.Encode()
+	}
+
+	pagination := chapa.Pagination{
+		PerPage:      perPage,
+		CurrentPage:  page,
+		FirstPageURL: pageURL(1),
+	}
+	if page > 1 {
+		pagination.PrevPageURL = pageURL(page - 1)
+	}
+	if page*perPage < total {
+		pagination.NextPageURL = pageURL(page + 1)
+	}
+
+	start := (page - 1) * perPage
+	if start > total {
+		start = total
+	}
+	end := start + perPage
+	if end > total {
+		end = total
+	}
+
+	return start, end, pagination
+}
+
+func toTransaction(data *chapa.VerifyData) chapa.Transaction {
+	return chapa.Transaction{
+		Status:        data.Status,
+		RefID:         data.Reference,
+		Type:          data.Type,
+		CreatedAt:     data.CreatedAt.UTC().Format(time.RFC3339),
+		Currency:      data.Currency,
+		Amount:        data.Amount,
+		Charge:        data.Charge.StringFixed(2),
+		TransID:       data.TxRef,
+		PaymentMethod: data.Method,
+		Customer: chapa.Customer{
+			Email:     data.Email,
+			FirstName: data.FirstName,
+			LastName:  data.LastName,
+		},
+	}
+}
+
+func customization(values map[string]interface{}) chapa.Customization {
+	text := func(key string) string {
+		value, _ := values[key].(string)
+		return value
+	}
+
+	return chapa.Customization{
+		Title:       text("title"),
+		Description: text("description"),
+		Logo:        text("logo"),
+	}
+}
+
+// decode reads the JSON body of r into v, answering with a 400 if it cannot.
+func decode(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return false
+	}
+	return true
+}
+
+// writeValidationError answers with the per-field messages of err, the way
+// Chapa reports validation failures.
+func writeValidationError(w http.ResponseWriter, err error) {
+	var validationErr *chapa.ValidationError
+	if !errors.As(err, &validationErr) {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	fields := make(map[string][]string, len(validationErr.Fields))
+	for field, message := range validationErr.Fields {
+		fields[field] = []string{message}
+	}
+	writeError(w, http.StatusBadRequest, fields)
+}
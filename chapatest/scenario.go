@@ -0,0 +1,255 @@
+package chapatest
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	chapa "github.com/Chapa-Et/chapa-go"
+	"github.com/shopspring/decimal"
+	"gopkg.in/yaml.v3"
+)
+
+// Kind is the kind of object a Scenario applies to.
+type Kind string
+
+const (
+	KindPayment  Kind = "payment"
+	KindTransfer Kind = "transfer"
+)
+
+// Scenario scripts the lifecycle of the payments or transfers it matches.
+// Scenarios are usually loaded from a YAML or JSON file:
+//
+//	scenarios:
+//	  - name: abandoned
+//	    match: {kind: payment, reference: "abandon-*"}
+//	    steps:
+//	      - {after: 30m, status: failed}
+//	  - name: reversed
+//	    match: {kind: payment, amount: ">=10000"}
+//	    steps:
+//	      - {after: 1m, status: success}
+//	      - {after: 48h, status: reversed}
+type Scenario struct {
+	Name  string `yaml:"name"`
+	Match Match  `yaml:"match"`
+	// Reject answers the request creating a matching object with a 400 and
+	// this message, instead of accepting it.
+	Reject string `yaml:"reject,omitempty"`
+	// Steps move matching objects along, relative to their creation time.
+	Steps []Step `yaml:"steps,omitempty"`
+}
+
+// Match selects the objects a Scenario applies to. Empty fields match anything.
+type Match struct {
+	Kind Kind `yaml:"kind,omitempty"`
+	// Reference is a path.Match pattern on the tx_ref of payments and the
+	// reference of transfers, e.g. "late-*".
+	Reference string `yaml:"reference,omitempty"`
+	// Amount is an exact amount such as "13.37", a comparison such as
+	// ">1000" or "<=5", or an inclusive range such as "100..500".
+	Amount string `yaml:"amount,omitempty"`
+}
+
+// Step moves an object to Status once After has passed since its creation.
+type Step struct {
+	After  time.Duration `yaml:"after"`
+	Status string        `yaml:"status"`
+	// Reason is the failure reason of failed and reversed transfers.
+	Reason string `yaml:"reason,omitempty"`
+}
+
+// LoadScenarios reads the scenarios of a YAML or JSON file.
+func LoadScenarios(path string) ([]Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	scenarios, err := ParseScenarios(data)
+	if err != nil {
+		return nil, fmt.Errorf("chapatest: %s: %w", path, err)
+	}
+	return scenarios, nil
+}
+
+// ParseScenarios decodes the scenarios of a YAML or JSON document.
+func ParseScenarios(data []byte) ([]Scenario, error) {
+	var document struct {
+		Scenarios []Scenario `yaml:"scenarios"`
+	}
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return nil, err
+	}
+
+	for _, scenario := range document.Scenarios {
+		if err := scenario.validate(); err != nil {
+			return nil, err
+		}
+	}
+	return document.Scenarios, nil
+}
+
+func (s Scenario) validate() error {
+	switch s.Match.Kind {
+	case "", KindPayment, KindTransfer:
+	default:
+		return fmt.Errorf("scenario %q: unknown kind %q", s.Name, s.Match.Kind)
+	}
+
+	if _, err := path.Match(s.Match.Reference, ""); err != nil {
+		return fmt.Errorf("scenario %q: reference: %w", s.Name, err)
+	}
+	if _, err := matchAmount(s.Match.Amount, decimal.Zero); err != nil {
+		return fmt.Errorf("scenario %q: amount: %w", s.Name, err)
+	}
+
+	for _, step := range s.Steps {
+		switch step.Status {
+		case "pending", "success", "failed", "reversed", "refunded":
+		default:
+			return fmt.Errorf("scenario %q: unknown status %q", s.Name, step.Status)
+		}
+	}
+	return nil
+}
+
+func (s Scenario) matches(kind Kind, reference string, amount decimal.Decimal) bool {
+	if s.Match.Kind != "" && s.Match.Kind != kind {
+		return false
+	}
+	if s.Match.Reference != "" {
+		if ok, _ := path.Match(s.Match.Reference, reference); !ok {
+			return false
+		}
+	}
+	ok, _ := matchAmount(s.Match.Amount, amount)
+	return ok
+}
+
+func matchAmount(pattern string, amount decimal.Decimal) (bool, error) {
+	pattern = strings.TrimSpace(pattern)
+	if pattern == "" {
+		return true, nil
+	}
+
+	if low, high, ok := strings.Cut(pattern, ".."); ok {
+		from, err := decimal.NewFromString(strings.TrimSpace(low))
+		if err != nil {
+			return false, err
+		}
+		to, err := decimal.NewFromString(strings.TrimSpace(high))
+		if err != nil {
+			return false, err
+		}
+		return amount.GreaterThanOrEqual(from) && amount.LessThanOrEqual(to), nil
+	}
+
+	for _, operator := range []string{">=", "<=", ">", "<"} {
+		if !strings.HasPrefix(pattern, operator) {
+			continue
+		}
+
+		value, err := decimal.NewFromString(strings.TrimSpace(strings.TrimPrefix(pattern, operator)))
+		if err != nil {
+			return false, err
+		}
+		switch operator {
+		case ">=":
+			return amount.GreaterThanOrEqual(value), nil
+		case "<=":
+			return amount.LessThanOrEqual(value), nil
+		case ">":
+			return amount.GreaterThan(value), nil
+		default:
+			return amount.LessThan(value), nil
+		}
+	}
+
+	value, err := decimal.NewFromString(pattern)
+	if err != nil {
+		return false, err
+	}
+	return amount.Equal(value), nil
+}
+
+// timeline tracks the scenario steps still to be applied to an object.
+type timeline struct {
+	kind      Kind
+	reference string
+	createdAt time.Time
+	steps     []Step
+}
+
+// scenario returns the first scenario matching an object, or nil. s.mu must
+// be held.
+func (s *Server) scenario(kind Kind, reference string, amount decimal.Decimal) *Scenario {
+	for i := range s.scenarios {
+		if s.scenarios[i].matches(kind, reference, amount) {
+			return &s.scenarios[i]
+		}
+	}
+	return nil
+}
+
+// follow schedules the steps of the scenario matching an object created now.
+// s.mu must be held.
+func (s *Server) follow(kind Kind, reference string, amount decimal.Decimal) {
+	if scenario := s.scenario(kind, reference, amount); scenario != nil && len(scenario.Steps) > 0 {
+		s.timelines = append(s.timelines, &timeline{
+			kind:      kind,
+			reference: reference,
+			createdAt: s.now(),
+			steps:     scenario.Steps,
+		})
+	}
+}
+
+// Advance moves the clock of the server forward by d and applies the
+// scenario steps that became due. The first call freezes the clock, which
+// only moves through Advance from then on.
+func (s *Server) Advance(d time.Duration) time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.clock.IsZero() {
+		s.clock = s.now()
+		s.now = func() time.Time { return s.clock }
+	}
+	s.clock = s.clock.Add(d)
+
+	s.tick()
+	return s.clock
+}
+
+// tick applies the scenario steps that are due. s.mu must be held.
+func (s *Server) tick() {
+	now := s.now()
+
+	pending := s.timelines[:0]
+	for _, timeline := range s.timelines {
+		for len(timeline.steps) > 0 {
+			step := timeline.steps[0]
+			at := timeline.createdAt.Add(step.After)
+			if at.After(now) {
+				break
+			}
+
+			switch timeline.kind {
+			case KindPayment:
+				s.setTransactionStatus(s.transactions[timeline.reference], chapa.TransactionStatus(step.Status), at)
+			case KindTransfer:
+				s.setTransferStatus(s.transfers[timeline.reference], chapa.TransferStatus(step.Status), step.Reason, at)
+			}
+			timeline.steps = timeline.steps[1:]
+		}
+
+		if len(timeline.steps) > 0 {
+			pending = append(pending, timeline)
+		}
+	}
+	s.timelines = pending
+}
@@ -0,0 +1,164 @@
+package chapatest
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	chapa "github.com/Chapa-Et/chapa-go"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScenarios(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2024, 1, 2, 9, 0, 0, 0, time.UTC)
+
+	scenarios, err := LoadScenarios("testdata/scenarios.yaml")
+	assert.NoError(t, err)
+	assert.Len(t, scenarios, 5)
+
+	newServer := func(t *testing.T) (*Server, chapa.API) {
+		server := NewServer(WithScenarios(scenarios...), WithClock(start))
+		t.Cleanup(server.Close)
+		return server, server.Client()
+	}
+
+	pay := func(t *testing.T, paymentProvider chapa.API, txRef string, amount int64) {
+		_, err := paymentProvider.PaymentRequest(&chapa.PaymentRequest{
+			Amount:         decimal.NewFromInt(amount),
+			Currency:       "ETB",
+			FirstName:      "Abebe",
+			LastName:       "Bikila",
+			Email:          "abebe@bikila.et",
+			CallbackURL:    "https://example.com/callback",
+			TransactionRef: txRef,
+		})
+		assert.NoError(t, err)
+	}
+
+	status := func(t *testing.T, paymentProvider chapa.API, txRef string) chapa.TransactionStatus {
+		response, err := paymentProvider.VerifyWithContext(ctx, txRef)
+		assert.NoError(t, err)
+		return response.Data.Status
+	}
+
+	transfer := func(reference, accountNumber string, amount float64) *chapa.BankTransfer {
+		return &chapa.BankTransfer{
+			AccountName:   "Leul Abay Ejigu",
+			AccountNumber: accountNumber,
+			Amount:        amount,
+			Currency:      "ETB",
+			Reference:     reference,
+			BankCode:      "946",
+		}
+	}
+
+	t.Run("abandons payments", func(t *testing.T) {
+		server, paymentProvider := newServer(t)
+		pay(t, paymentProvider, "abandon-1", 100)
+
+		server.Advance(29 * time.Minute)
+		assert.Equal(t, chapa.PendingTransactionStatus, status(t, paymentProvider, "abandon-1"))
+
+		server.Advance(time.Minute)
+		assert.Equal(t, chapa.FailedTransactionStatus, status(t, paymentProvider, "abandon-1"))
+	})
+
+	t.Run("pays late", func(t *testing.T) {
+		server, paymentProvider := newServer(t)
+		pay(t, paymentProvider, "late-1", 100)
+
+		server.Advance(time.Hour)
+		assert.Equal(t, chapa.PendingTransactionStatus, status(t, paymentProvider, "late-1"))
+
+		server.Advance(5 * time.Hour)
+		response, err := paymentProvider.VerifyWithContext(ctx, "late-1")
+		assert.NoError(t, err)
+		assert.Equal(t, chapa.SuccessTransactionStatus, response.Data.Status)
+		assert.True(t, start.Add(2*time.Hour).Equal(response.Data.UpdatedAt))
+	})
+
+	t.Run("reverses charges by amount", func(t *testing.T) {
+		server, paymentProvider := newServer(t)
+		pay(t, paymentProvider, "big-1", 25000)
+		pay(t, paymentProvider, "small-1", 100)
+
+		server.Advance(time.Hour)
+		assert.Equal(t, chapa.SuccessTransactionStatus, status(t, paymentProvider, "big-1"))
+
+		server.Advance(72 * time.Hour)
+		assert.Equal(t, chapa.ReversedTransactionStatus, status(t, paymentProvider, "big-1"))
+		assert.Equal(t, chapa.PendingTransactionStatus, status(t, paymentProvider, "small-1"))
+	})
+
+	t.Run("rejects transfers to accounts of the wrong length", func(t *testing.T) {
+		_, paymentProvider := newServer(t)
+
+		_, err := paymentProvider.TransferToBank(transfer("ref-1", "12345", 10))
+		var apiErr *chapa.APIError
+		assert.ErrorAs(t, err, &apiErr)
+		assert.ErrorIs(t, err, chapa.ErrBadRequest)
+		assert.Contains(t, apiErr.Fields["account_number"][0], "13 digits")
+	})
+
+	t.Run("rejects and bounces transfers", func(t *testing.T) {
+		server, paymentProvider := newServer(t)
+
+		_, err := paymentProvider.TransferToBank(transfer("frozen-1", "1000212482106", 10))
+		assert.ErrorIs(t, err, chapa.ErrBadRequest)
+		assert.Contains(t, err.Error(), "The recipient account is frozen")
+
+		_, err = paymentProvider.TransferToBank(transfer("payout-1", "1000212482106", 750))
+		assert.NoError(t, err)
+
+		server.Advance(4 * time.Hour)
+		transfer, ok := server.Transfer("payout-1")
+		assert.True(t, ok)
+		assert.Equal(t, chapa.ReversedTransferStatus, transfer.Status)
+		assert.Equal(t, "Account closed", transfer.FailureReason)
+	})
+
+	t.Run("advances over HTTP", func(t *testing.T) {
+		server, paymentProvider := newServer(t)
+		pay(t, paymentProvider, "abandon-1", 100)
+
+		resp, err := http.Post(server.URL+"/simulator/advance?by=1h", "", nil)
+		assert.NoError(t, err)
+		defer resp.Body.Close()
+
+		var body struct {
+			Data struct {
+				Now time.Time `json:"now"`
+			} `json:"data"`
+		}
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		assert.True(t, start.Add(time.Hour).Equal(body.Data.Now))
+		assert.Equal(t, chapa.FailedTransactionStatus, status(t, paymentProvider, "abandon-1"))
+	})
+
+	t.Run("parses JSON scenarios", func(t *testing.T) {
+		scenarios, err := ParseScenarios([]byte(`{"scenarios": [
+			{"name": "slow", "match": {"amount": "<5"}, "steps": [{"after": "90s", "status": "success"}]}
+		]}`))
+		assert.NoError(t, err)
+		assert.Equal(t, 90*time.Second, scenarios[0].Steps[0].After)
+		assert.True(t, scenarios[0].matches(KindPayment, "any", decimal.NewFromInt(4)))
+		assert.False(t, scenarios[0].matches(KindTransfer, "any", decimal.NewFromInt(5)))
+	})
+
+	t.Run("rejects invalid scenarios", func(t *testing.T) {
+		for _, document := range []string{
+			`scenarios: [{name: a, match: {kind: refund}}]`,
+			`scenarios: [{name: a, match: {amount: "lots"}}]`,
+			`scenarios: [{name: a, match: {reference: "["}}]`,
+			`scenarios: [{name: a, steps: [{after: 1m, status: paid}]}]`,
+			`scenarios: [{name: a, steps: [{after: soon, status: success}]}]`,
+		} {
+			_, err := ParseScenarios([]byte(document))
+			assert.Error(t, err, document)
+		}
+	})
+}
@@ -0,0 +1,404 @@
+// Package chapatest provides an in-process fake of the Chapa API, so that code
+// built on the chapa client can be tested end-to-end without network access.
+//
+// The fake implements the initialize, direct charge, verify, transaction
+// events, transactions, refund, banks, transfers, bulk-transfers and
+// subaccount endpoints. Transactions and transfers start out pending
+// and are moved along by the test through methods such as Complete and Fail,
+// or by Scenario steps applied as the simulated clock advances.
+//
+// Recorder and Replayer capture real sandbox exchanges in a cassette file and
+// serve them back, for tests that must run against recorded Chapa responses.
+package chapatest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	chapa "github.com/Chapa-Et/chapa-go"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	// DefaultAPIKey is the secret key the Server accepts unless WithAPIKey is used.
+	DefaultAPIKey = "CHASECK_TEST-chapatest"
+	// TestOTP is the only OTP that authorizes direct charges.
+	TestOTP = "123456"
+)
+
+// Failure describes an injected failure. Requests matching Method and Path
+// are answered with StatusCode and Body instead of being served.
+type Failure struct {
+	// Method matches the request method. Empty matches any method.
+	Method string
+	// Path matches endpoint paths starting with it, without the version
+	// prefix, e.g. "/transfers". Empty matches any path.
+	Path string
+	// StatusCode is the status of the response. Defaults to 500.
+	StatusCode int
+	// Body is the response body. Defaults to a Chapa style error message.
+	Body string
+	// Header is added to the response, e.g. Retry-After.
+	Header http.Header
+	// Delay is waited before responding.
+	Delay time.Duration
+	// DropConnection closes the connection without responding, after the
+	// request has been served, so the caller cannot tell whether it succeeded.
+	DropConnection bool
+	// Times is the number of requests to fail. Zero fails every request.
+	Times int
+}
+
+// Server is a fake Chapa API served over HTTP.
+type Server struct {
+	*httptest.Server
+
+	// APIKey is the secret key the server accepts.
+	APIKey string
+
+	listener net.Listener
+
+	mu            sync.Mutex
+	now           func() time.Time
+	clock         time.Time
+	scenarios     []Scenario
+	timelines     []*timeline
+	banks         []chapa.Bank
+	failures      []*Failure
+	sequence      int
+	transactions  map[string]*chapa.VerifyData
+	txOrder       []string
+	txLogs        map[string][]chapa.TransactionLog
+	refunds       map[string]decimal.Decimal
+	refundRefs    map[string]bool
+	transfers     map[string]*chapa.Transfer
+	transferOrder []string
+	batches       map[int][]string
+	subaccounts   []chapa.Subaccount
+}
+
+// Option configures a Server created with NewServer.
+type Option func(*Server)
+
+// WithAPIKey sets the secret key the Server accepts.
+func WithAPIKey(apiKey string) Option {
+	return func(s *Server) {
+		s.APIKey = apiKey
+	}
+}
+
+// WithBanks replaces the banks returned by the banks endpoint.
+func WithBanks(banks ...chapa.Bank) Option {
+	return func(s *Server) {
+		s.banks = banks
+	}
+}
+
+// WithScenarios scripts the lifecycle of the payments and transfers matching
+// scenarios. A Scenario file is loaded with LoadScenarios.
+func WithScenarios(scenarios ...Scenario) Option {
+	return func(s *Server) {
+		s.scenarios = append(s.scenarios, scenarios...)
+	}
+}
+
+// WithClock freezes the clock of the server at start. It then only moves
+// through Advance.
+func WithClock(start time.Time) Option {
+	return func(s *Server) {
+		s.clock = start
+		s.now = func() time.Time { return s.clock }
+	}
+}
+
+// WithListener serves on l instead of a listener on a random local port.
+func WithListener(l net.Listener) Option {
+	return func(s *Server) {
+		s.listener = l
+	}
+}
+
+// NewServer starts a Server. The caller must Close it when done.
+func NewServer(opts ...Option) *Server {
+	s := &Server{
+		APIKey:       DefaultAPIKey,
+		now:          time.Now,
+		banks:        defaultBanks(),
+		transactions: make(map[string]*chapa.VerifyData),
+		txLogs:       make(map[string][]chapa.TransactionLog),
+		refunds:      make(map[string]decimal.Decimal),
+		refundRefs:   make(map[string]bool),
+		transfers:    make(map[string]*chapa.Transfer),
+		batches:      make(map[int][]string),
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	s.Server = httptest.NewUnstartedServer(http.HandlerFunc(s.serveHTTP))
+	if s.listener != nil {
+		s.Server.Listener.Close()
+		s.Server.Listener = s.listener
+	}
+	s.Server.Start()
+	return s
+}
+
+// Client returns a chapa client talking to the server with its API key.
+func (s *Server) Client(opts ...chapa.Option) chapa.API {
+	opts = append([]chapa.Option{chapa.WithBaseURL(s.URL)}, opts...)
+	return chapa.NewClient(s.APIKey, opts...)
+}
+
+// InjectFailure makes matching requests fail. Failures are matched in the
+// order they were injected.
+func (s *Server) InjectFailure(failure Failure) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.failures = append(s.failures, &failure)
+}
+
+// ClearFailures removes every injected failure.
+func (s *Server) ClearFailures() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.failures = nil
+}
+
+// Complete marks the transaction with txRef as successfully paid.
+func (s *Server) Complete(txRef string) error {
+	return s.SetTransactionStatus(txRef, chapa.SuccessTransactionStatus)
+}
+
+// Fail marks the transaction with txRef as failed.
+func (s *Server) Fail(txRef string) error {
+	return s.SetTransactionStatus(txRef, chapa.FailedTransactionStatus)
+}
+
+// SetTransactionStatus moves the transaction with txRef to status.
+func (s *Server) SetTransactionStatus(txRef string, status chapa.TransactionStatus) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	transaction, ok := s.transactions[txRef]
+	if !ok {
+		return fmt.Errorf("chapatest: unknown transaction %q", txRef)
+	}
+
+	s.setTransactionStatus(transaction, status, s.now())
+	return nil
+}
+
+func (s *Server) setTransactionStatus(transaction *chapa.VerifyData, status chapa.TransactionStatus, at time.Time) {
+	transaction.Status = status
+	transaction.UpdatedAt = at
+	if status == chapa.SuccessTransactionStatus && transaction.Method == "" {
+		transaction.Method = "test"
+	}
+
+	s.logTransaction(transaction.TxRef, "log", "Payment status changed to "+string(status), at)
+}
+
+// logTransaction adds an event to the history of the transaction with txRef.
+// s.mu must be held.
+func (s *Server) logTransaction(txRef, eventType, message string, at time.Time) {
+	s.sequence++
+	s.txLogs[txRef] = append(s.txLogs[txRef], chapa.TransactionLog{
+		Item:      strconv.Itoa(s.sequence),
+		Message:   message,
+		Type:      eventType,
+		CreatedAt: at,
+		UpdatedAt: at,
+	})
+}
+
+// Transaction returns the transaction with txRef as the verify endpoint reports it.
+func (s *Server) Transaction(txRef string) (chapa.VerifyData, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tick()
+	transaction, ok := s.transactions[txRef]
+	if !ok {
+		return chapa.VerifyData{}, false
+	}
+	return *transaction, true
+}
+
+// SetTransferStatus moves the transfer with reference to status. reason is
+// reported as the failure reason of failed and reversed transfers.
+func (s *Server) SetTransferStatus(reference string, status chapa.TransferStatus, reason string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	transfer, ok := s.transfers[reference]
+	if !ok {
+		return fmt.Errorf("chapatest: unknown transfer %q", reference)
+	}
+
+	s.setTransferStatus(transfer, status, reason, s.now())
+	return nil
+}
+
+func (s *Server) setTransferStatus(transfer *chapa.Transfer, status chapa.TransferStatus, reason string, at time.Time) {
+	transfer.Status = status
+	transfer.FailureReason = reason
+	transfer.UpdatedAt = at
+}
+
+// Transfer returns the transfer with reference as the verify endpoint reports it.
+func (s *Server) Transfer(reference string) (chapa.Transfer, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tick()
+	transfer, ok := s.transfers[reference]
+	if !ok {
+		return chapa.Transfer{}, false
+	}
+	return *transfer, true
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if failure := s.matchFailure(r); failure != nil {
+		s.fail(w, r, failure)
+		return
+	}
+
+	if r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, checkoutPath) {
+		s.checkout(w, r)
+		return
+	}
+	if r.Method == http.MethodPost && r.URL.Path == advancePath {
+		s.advance(w, r)
+		return
+	}
+
+	if r.Header.Get("Authorization") != "Bearer "+s.APIKey {
+		writeError(w, http.StatusUnauthorized, "Invalid API Key or User doesn't exist")
+		return
+	}
+
+	s.route(w, r)
+}
+
+// matchFailure returns the first injected failure matching r, consuming one
+// of its times.
+func (s *Server) matchFailure(r *http.Request) *Failure {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path := endpointPath(r.URL.Path)
+	for i, failure := range s.failures {
+		if failure.Method != "" && failure.Method != r.Method {
+			continue
+		}
+		if !strings.HasPrefix(path, failure.Path) {
+			continue
+		}
+
+		if failure.Times > 0 {
+			failure.Times--
+			if failure.Times == 0 {
+				s.failures = append(s.failures[:i:i], s.failures[i+1:]...)
+			}
+		}
+		return failure
+	}
+
+	return nil
+}
+
+func (s *Server) fail(w http.ResponseWriter, r *http.Request, failure *Failure) {
+	if failure.Delay > 0 {
+		select {
+		case <-time.After(failure.Delay):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	if failure.DropConnection {
+		s.route(httptest.NewRecorder(), r)
+
+		hijacker, ok := w.(http.Hijacker)
+		if !ok {
+			return
+		}
+		if conn, _, err := hijacker.Hijack(); err == nil {
+			if tcp, ok := conn.(*net.TCPConn); ok {
+				_ = tcp.SetLinger(0)
+			}
+			conn.Close()
+		}
+		return
+	}
+
+	for key, values := range failure.Header {
+		w.Header()[key] = values
+	}
+
+	status := failure.StatusCode
+	if status == 0 {
+		status = http.StatusInternalServerError
+	}
+	if failure.Body != "" {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(failure.Body))
+		return
+	}
+	writeError(w, status, http.StatusText(status))
+}
+
+// endpointPath strips the version prefix from an API path.
+func endpointPath(path string) string {
+	if rest := strings.TrimPrefix(path, "/v1"); rest != path {
+		return rest
+	}
+	return path
+}
+
+// nextReference returns a unique Chapa style reference with prefix.
+func (s *Server) nextReference(prefix string) string {
+	s.sequence++
+	return fmt.Sprintf("%s%08d", prefix, s.sequence)
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func writeError(w http.ResponseWriter, status int, message interface{}) {
+	writeJSON(w, status, map[string]interface{}{
+		"message": message,
+		"status":  "failed",
+		"data":    nil,
+	})
+}
+
+func defaultBanks() []chapa.Bank {
+	return []chapa.Bank{
+		{ID: 946, Swift: "CBETETAA", Name: "Commercial Bank of Ethiopia (CBE)", AcctLength: 13, CountryID: 1, Currency: chapa.ETB},
+		{ID: 656, Swift: "AWINETAA", Name: "Awash Bank", AcctLength: 14, CountryID: 1, Currency: chapa.ETB},
+		{ID: 128, Swift: "BUNAETAA", Name: "Bunna Bank", AcctLength: 13, CountryID: 1, Currency: chapa.ETB},
+		{ID: 855, Swift: "TELEBIRR", Name: "telebirr", AcctLength: 10, CountryID: 1, IsMobileMoney: 1, Currency: chapa.ETB},
+	}
+}
+
+// charge is the fee the fake applies to payments and transfers.
+func charge(amount decimal.Decimal) decimal.Decimal {
+	return amount.Mul(decimal.RequireFromString("0.035")).Round(2)
+}
@@ -0,0 +1,416 @@
+package chapatest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	chapa "github.com/Chapa-Et/chapa-go"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer(t *testing.T) {
+	ctx := context.Background()
+
+	paymentRequest := func(txRef string) *chapa.PaymentRequest {
+		return &chapa.PaymentRequest{
+			Amount:         decimal.NewFromInt(100),
+			Currency:       "ETB",
+			FirstName:      "Abebe",
+			LastName:       "Bikila",
+			Email:          "abebe@bikila.et",
+			CallbackURL:    "https://example.com/callback",
+			TransactionRef: txRef,
+			Customization:  map[string]interface{}{"title": "Order"},
+		}
+	}
+
+	bankTransfer := func(reference string) *chapa.BankTransfer {
+		return &chapa.BankTransfer{
+			AccountName:   "Leul Abay Ejigu",
+			AccountNumber: "1000212482106",
+			Amount:        10,
+			Currency:      "ETB",
+			Reference:     reference,
+			BankCode:      "946",
+		}
+	}
+
+	t.Run("initializes and verifies payments", func(t *testing.T) {
+		server := NewServer()
+		defer server.Close()
+		paymentProvider := server.Client()
+
+		response, err := paymentProvider.PaymentRequestWithContext(ctx, paymentRequest("tx-1"))
+		assert.NoError(t, err)
+		assert.Equal(t, "success", response.Status)
+		assert.Equal(t, server.URL+"/checkout/payment/tx-1", response.Data.CheckoutURL)
+
+		verified, err := paymentProvider.VerifyWithContext(ctx, "tx-1")
+		assert.NoError(t, err)
+		assert.Equal(t, chapa.PendingTransactionStatus, verified.Data.Status)
+		assert.True(t, decimal.NewFromInt(100).Equal(verified.Data.Amount))
+		assert.True(t, decimal.RequireFromString("3.5").Equal(verified.Data.Charge))
+		assert.Equal(t, "Order", verified.Data.Customization.Title)
+		assert.False(t, verified.Data.CreatedAt.IsZero())
+
+		assert.NoError(t, server.Complete("tx-1"))
+		verified, err = paymentProvider.VerifyWithContext(ctx, "tx-1")
+		assert.NoError(t, err)
+		assert.Equal(t, chapa.SuccessTransactionStatus, verified.Data.Status)
+
+		assert.Error(t, server.Complete("unknown"))
+	})
+
+	t.Run("completes payments through the checkout URL", func(t *testing.T) {
+		server := NewServer()
+		defer server.Close()
+
+		response, err := server.Client().PaymentRequest(paymentRequest("tx-1"))
+		assert.NoError(t, err)
+
+		resp, err := http.Get(response.Data.CheckoutURL)
+		assert.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		transaction, ok := server.Transaction("tx-1")
+		assert.True(t, ok)
+		assert.Equal(t, chapa.SuccessTransactionStatus, transaction.Status)
+
+		logs, err := server.Client().GetTransactionLogs(ctx, "tx-1")
+		assert.NoError(t, err)
+		assert.Len(t, logs.Data, 3)
+		assert.Equal(t, "checkout", logs.Data[1].Type)
+		assert.Equal(t, "Payment status changed to success", logs.Data[2].Message)
+
+		_, err = server.Client().GetTransactionLogs(ctx, "unknown")
+		assert.ErrorIs(t, err, chapa.ErrNotFound)
+	})
+
+	t.Run("rejects bad payment requests", func(t *testing.T) {
+		server := NewServer()
+		defer server.Close()
+		paymentProvider := server.Client()
+
+		_, err := paymentProvider.PaymentRequest(paymentRequest("tx-1"))
+		assert.NoError(t, err)
+
+		_, err = paymentProvider.PaymentRequest(paymentRequest("tx-1"))
+		assert.ErrorIs(t, err, chapa.ErrBadRequest)
+
+		request := paymentRequest("tx-2")
+		request.Currency = "EUR"
+		_, err = paymentProvider.PaymentRequest(request)
+		var apiErr *chapa.APIError
+		assert.True(t, errors.As(err, &apiErr))
+		assert.Contains(t, apiErr.Fields, "currency")
+
+		_, err = paymentProvider.Verify("unknown")
+		assert.ErrorIs(t, err, chapa.ErrNotFound)
+	})
+
+	t.Run("refunds payments", func(t *testing.T) {
+		server := NewServer()
+		defer server.Close()
+		paymentProvider := server.Client()
+
+		_, err := paymentProvider.PaymentRequest(paymentRequest("tx-1"))
+		assert.NoError(t, err)
+
+		_, err = paymentProvider.Refund(ctx, "tx-1", &chapa.RefundRequest{Amount: decimal.NewFromInt(10)})
+		assert.ErrorIs(t, err, chapa.ErrNotRefundable)
+
+		assert.NoError(t, server.Complete("tx-1"))
+		response, err := paymentProvider.Refund(ctx, "tx-1", &chapa.RefundRequest{Amount: decimal.NewFromInt(40), Reference: "refund-1"})
+		assert.NoError(t, err)
+		assert.Equal(t, "refund-1", response.Data.Reference)
+		assert.True(t, decimal.NewFromInt(40).Equal(response.Data.Amount))
+
+		transaction, _ := server.Transaction("tx-1")
+		assert.Equal(t, chapa.SuccessTransactionStatus, transaction.Status)
+
+		_, err = paymentProvider.Refund(ctx, "tx-1", &chapa.RefundRequest{Amount: decimal.NewFromInt(60)})
+		assert.NoError(t, err)
+
+		transaction, _ = server.Transaction("tx-1")
+		assert.Equal(t, chapa.RefundedTransactionStatus, transaction.Status)
+
+		_, err = paymentProvider.Refund(ctx, "tx-1", &chapa.RefundRequest{Amount: decimal.NewFromInt(1)})
+		assert.ErrorIs(t, err, chapa.ErrNotRefundable)
+
+		// the fake refuses what the client would have caught
+		_, err = server.Client(chapa.WithRefundLedger(nil)).Refund(ctx, "tx-1", &chapa.RefundRequest{Amount: decimal.NewFromInt(1)})
+		assert.ErrorIs(t, err, chapa.ErrBadRequest)
+	})
+
+	t.Run("splits payments with subaccounts", func(t *testing.T) {
+		server := NewServer()
+		defer server.Close()
+		paymentProvider := server.Client()
+
+		created, err := paymentProvider.CreateSubaccount(ctx, &chapa.Subaccount{
+			BusinessName:  "Abebe Souq",
+			AccountName:   "Abebe Bikila",
+			BankCode:      "946",
+			AccountNumber: "1000212482106",
+			SplitType:     chapa.FlatSplit,
+			SplitValue:    decimal.NewFromInt(25),
+		})
+		assert.NoError(t, err)
+
+		listed, err := paymentProvider.ListSubaccounts(ctx)
+		assert.NoError(t, err)
+		assert.Len(t, listed.Data, 1)
+		assert.Equal(t, created.Data.ID, listed.Data[0].ID)
+
+		request := paymentRequest("tx-1")
+		request.Subaccounts = []chapa.SubaccountSplit{{ID: created.Data.ID}}
+		_, err = paymentProvider.PaymentRequest(request)
+		assert.NoError(t, err)
+
+		request = paymentRequest("tx-2")
+		request.Subaccounts = []chapa.SubaccountSplit{{ID: "unknown"}}
+		_, err = paymentProvider.PaymentRequest(request)
+		assert.ErrorIs(t, err, chapa.ErrBadRequest)
+	})
+
+	t.Run("charges mobile wallets directly", func(t *testing.T) {
+		server := NewServer()
+		defer server.Close()
+		paymentProvider := server.Client()
+
+		directCharge := func(txRef string) *chapa.DirectChargeRequest {
+			return &chapa.DirectChargeRequest{
+				Amount:         decimal.NewFromInt(100),
+				Currency:       "ETB",
+				Mobile:         "0912345678",
+				TransactionRef: txRef,
+			}
+		}
+
+		charged, err := paymentProvider.DirectCharge(ctx, chapa.Telebirr, directCharge("tx-1"))
+		assert.NoError(t, err)
+		assert.Equal(t, chapa.USSDAuth, charged.Data.AuthType)
+
+		verified, err := paymentProvider.VerifyWithContext(ctx, "tx-1")
+		assert.NoError(t, err)
+		assert.Equal(t, chapa.PendingTransactionStatus, verified.Data.Status)
+		assert.Equal(t, "telebirr", verified.Data.Method)
+
+		assert.NoError(t, server.Complete("tx-1"))
+		verified, err = paymentProvider.VerifyWithContext(ctx, "tx-1")
+		assert.NoError(t, err)
+		assert.Equal(t, chapa.SuccessTransactionStatus, verified.Data.Status)
+
+		_, err = paymentProvider.DirectCharge(ctx, chapa.Telebirr, directCharge("tx-1"))
+		assert.ErrorIs(t, err, chapa.ErrBadRequest)
+
+		charged, err = paymentProvider.DirectCharge(ctx, chapa.Mpesa, directCharge("tx-2"))
+		assert.NoError(t, err)
+		assert.Equal(t, chapa.OTPAuth, charged.Data.AuthType)
+
+		_, err = paymentProvider.AuthorizeDirectCharge(ctx, charged.Data.Reference, "000000")
+		assert.ErrorIs(t, err, chapa.ErrBadRequest)
+
+		authorized, err := paymentProvider.AuthorizeDirectCharge(ctx, charged.Data.Reference, TestOTP)
+		assert.NoError(t, err)
+		assert.Equal(t, chapa.SuccessTransactionStatus, authorized.Data.Status)
+
+		transaction, _ := server.Transaction("tx-2")
+		assert.Equal(t, chapa.SuccessTransactionStatus, transaction.Status)
+
+		_, err = paymentProvider.AuthorizeDirectCharge(ctx, "unknown", TestOTP)
+		assert.ErrorIs(t, err, chapa.ErrNotFound)
+	})
+
+	t.Run("lists transactions newest first", func(t *testing.T) {
+		server := NewServer()
+		defer server.Close()
+		paymentProvider := server.Client()
+
+		for i := 1; i <= 12; i++ {
+			_, err := paymentProvider.PaymentRequest(paymentRequest(fmt.Sprintf("tx-%d", i)))
+			assert.NoError(t, err)
+		}
+
+		response, err := paymentProvider.GetTransactions()
+		assert.NoError(t, err)
+		assert.Len(t, response.Data.Transactions, 10)
+		assert.Equal(t, "tx-12", response.Data.Transactions[0].TransID)
+		assert.Equal(t, 1, response.Data.Pagination.CurrentPage)
+		assert.Contains(t, response.Data.Pagination.NextPageURL, "page=2")
+		assert.Empty(t, response.Data.Pagination.PrevPageURL)
+
+		var count int
+		it := paymentProvider.Transactions(ctx, &chapa.TransactionFilter{PerPage: 5}, chapa.WithPrefetch())
+		for it.Next() {
+			count++
+		}
+		assert.NoError(t, it.Err())
+		assert.Equal(t, 12, count)
+	})
+
+	t.Run("filters transactions", func(t *testing.T) {
+		server := NewServer()
+		defer server.Close()
+		paymentProvider := server.Client()
+
+		for i := 1; i <= 3; i++ {
+			request := paymentRequest(fmt.Sprintf("tx-%d", i))
+			if i == 3 {
+				request.Currency = "USD"
+				request.Email = "other@example.com"
+			}
+			_, err := paymentProvider.PaymentRequest(request)
+			assert.NoError(t, err)
+		}
+		assert.NoError(t, server.Complete("tx-2"))
+
+		list := func(filter *chapa.TransactionFilter) []string {
+			response, err := paymentProvider.ListTransactions(ctx, filter)
+			assert.NoError(t, err)
+
+			var ids []string
+			for _, transaction := range response.Data.Transactions {
+				ids = append(ids, transaction.TransID)
+			}
+			return ids
+		}
+
+		assert.Equal(t, []string{"tx-2"}, list(&chapa.TransactionFilter{Status: chapa.SuccessTransactionStatus}))
+		assert.Equal(t, []string{"tx-3"}, list(&chapa.TransactionFilter{Currency: "USD"}))
+		assert.Equal(t, []string{"tx-3"}, list(&chapa.TransactionFilter{Customer: "OTHER@example.com"}))
+		assert.Equal(t, []string{"tx-1"}, list(&chapa.TransactionFilter{Reference: "tx-1"}))
+		assert.Equal(t, []string{"tx-2"}, list(&chapa.TransactionFilter{PaymentMethod: "test"}))
+		assert.Len(t, list(&chapa.TransactionFilter{From: time.Now().AddDate(0, 0, -1), To: time.Now()}), 3)
+		assert.Empty(t, list(&chapa.TransactionFilter{To: time.Now().AddDate(0, 0, -1)}))
+	})
+
+	t.Run("lists banks", func(t *testing.T) {
+		server := NewServer(WithBanks(chapa.Bank{ID: 1, Name: "Test Bank", AcctLength: 10}))
+		defer server.Close()
+
+		response, err := server.Client().GetBanks()
+		assert.NoError(t, err)
+		assert.Len(t, response.Data, 1)
+		assert.Equal(t, "Test Bank", response.Data[0].Name)
+	})
+
+	t.Run("queues, verifies and lists transfers", func(t *testing.T) {
+		server := NewServer()
+		defer server.Close()
+		paymentProvider := server.Client()
+
+		response, err := paymentProvider.TransferToBank(bankTransfer("ref-1"))
+		assert.NoError(t, err)
+		assert.Equal(t, "success", response.Status)
+		assert.NotEmpty(t, response.Data)
+
+		verified, err := paymentProvider.VerifyTransfer(ctx, "ref-1")
+		assert.NoError(t, err)
+		assert.Equal(t, chapa.PendingTransferStatus, verified.Data.Status)
+		assert.Equal(t, response.Data, verified.Data.ChapaReference)
+		assert.Equal(t, "Commercial Bank of Ethiopia (CBE)", verified.Data.BankName)
+
+		_, err = paymentProvider.TransferToBank(bankTransfer("ref-2"))
+		assert.NoError(t, err)
+		assert.NoError(t, server.SetTransferStatus("ref-2", chapa.FailedTransferStatus, "Insufficient balance"))
+
+		listed, err := paymentProvider.ListTransfers(ctx, &chapa.TransferFilter{Status: chapa.FailedTransferStatus})
+		assert.NoError(t, err)
+		assert.Len(t, listed.Data, 1)
+		assert.Equal(t, "ref-2", listed.Data[0].Reference)
+		assert.Equal(t, "Insufficient balance", listed.Data[0].FailureReason)
+
+		listed, err = paymentProvider.ListTransfers(ctx, &chapa.TransferFilter{From: time.Now().AddDate(0, 0, 1)})
+		assert.NoError(t, err)
+		assert.Empty(t, listed.Data)
+
+		_, err = paymentProvider.VerifyTransfer(ctx, "unknown")
+		assert.ErrorIs(t, err, chapa.ErrNotFound)
+
+		transfer := bankTransfer("ref-3")
+		transfer.BankCode = "1"
+		_, err = paymentProvider.TransferToBank(transfer)
+		assert.ErrorIs(t, err, chapa.ErrBadRequest)
+	})
+
+	t.Run("tracks bulk transfers", func(t *testing.T) {
+		server := NewServer()
+		defer server.Close()
+		paymentProvider := server.Client()
+
+		response, err := paymentProvider.BulkTransfer(&chapa.BulkTransferRequest{
+			Title:    "Payroll",
+			Currency: "ETB",
+			BulkData: []chapa.BulkData{
+				{AccountName: "Leul Abay Ejigu", AccountNumber: "1000212482106", Amount: 10, Reference: "bulk-1", BankCode: "946"},
+				{AccountName: "Leul Abay Ejigu", AccountNumber: "0912345678", Amount: 20, Reference: "bulk-2", BankCode: "855"},
+			},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, response.Data.ID)
+
+		assert.NoError(t, server.SetTransferStatus("bulk-1", chapa.SuccessTransferStatus, ""))
+		assert.NoError(t, server.SetTransferStatus("bulk-2", chapa.FailedTransferStatus, "Invalid account"))
+
+		result, err := paymentProvider.GetBulkTransfer(ctx, response.Data.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, chapa.PartialBulkTransferStatus, result.Status)
+		assert.Len(t, result.Transfers, 2)
+		assert.Equal(t, "bulk-2", result.Failed()[0].Reference)
+		assert.Equal(t, "mobile_money", result.Failed()[0].TransferMethod)
+	})
+
+	t.Run("injects failures", func(t *testing.T) {
+		server := NewServer()
+		defer server.Close()
+		policy := chapa.RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: 10 * time.Millisecond}
+		paymentProvider := server.Client(chapa.WithRetryPolicy(policy))
+
+		server.InjectFailure(Failure{Path: "/banks", StatusCode: http.StatusServiceUnavailable, Times: 2})
+		_, err := paymentProvider.GetBanks()
+		assert.NoError(t, err)
+
+		server.InjectFailure(Failure{Path: "/banks", StatusCode: http.StatusTooManyRequests, Body: `{"message":"Slow down"}`})
+		_, err = paymentProvider.GetBanks()
+		assert.ErrorIs(t, err, chapa.ErrRateLimited)
+
+		server.ClearFailures()
+		_, err = paymentProvider.GetBanks()
+		assert.NoError(t, err)
+	})
+
+	t.Run("drops connections after serving the request", func(t *testing.T) {
+		server := NewServer()
+		defer server.Close()
+		paymentProvider := server.Client()
+
+		server.InjectFailure(Failure{Method: http.MethodPost, Path: "/transfers", DropConnection: true, Times: 1})
+		_, err := paymentProvider.TransferToBank(bankTransfer("ref-1"))
+		assert.Error(t, err)
+
+		_, ok := server.Transfer("ref-1")
+		assert.True(t, ok)
+
+		// The client finds the transfer went through and refuses to resend it.
+		_, err = paymentProvider.TransferToBank(bankTransfer("ref-1"))
+		assert.ErrorIs(t, err, chapa.ErrDuplicateTransfer)
+	})
+
+	t.Run("rejects unknown API keys", func(t *testing.T) {
+		server := NewServer(WithAPIKey("secret"))
+		defer server.Close()
+
+		_, err := chapa.NewClient("wrong", chapa.WithBaseURL(server.URL)).GetBanks()
+		assert.ErrorIs(t, err, chapa.ErrUnauthorized)
+
+		_, err = server.Client().GetBanks()
+		assert.NoError(t, err)
+	})
+}
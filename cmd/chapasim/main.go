@@ -0,0 +1,56 @@
+// Command chapasim serves a local stand-in for the Chapa v1 API, driven by
+// scenario files, for testing applications offline.
+//
+//	chapasim -addr 127.0.0.1:8089 -scenarios scenarios.yaml
+//
+// Point the client at it with chapa.WithBaseURL and the printed API key. The
+// simulated clock starts at the current time and moves forward with
+//
+//	curl -X POST 'http://127.0.0.1:8089/simulator/advance?by=2h'
+package main
+
+import (
+	"flag"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/Chapa-Et/chapa-go/chapatest"
+)
+
+func main() {
+	addr := flag.String("addr", "127.0.0.1:8089", "address to listen on")
+	scenariosPath := flag.String("scenarios", "", "YAML or JSON scenario file")
+	apiKey := flag.String("api-key", chapatest.DefaultAPIKey, "secret key accepted by the simulator")
+	flag.Parse()
+
+	listener, err := net.Listen("tcp", *addr)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	opts := []chapatest.Option{
+		chapatest.WithListener(listener),
+		chapatest.WithAPIKey(*apiKey),
+		chapatest.WithClock(time.Now()),
+	}
+	if *scenariosPath != "" {
+		scenarios, err := chapatest.LoadScenarios(*scenariosPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		opts = append(opts, chapatest.WithScenarios(scenarios...))
+		log.Printf("loaded %d scenarios from %s", len(scenarios), *scenariosPath)
+	}
+
+	server := chapatest.NewServer(opts...)
+	defer server.Close()
+	log.Printf("serving Chapa API at %s/v1 with key %s", server.URL, server.APIKey)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	<-signals
+}
@@ -1,6 +1,14 @@
 package chapa
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"time"
+
 	validation "github.com/go-ozzo/ozzo-validation/v4"
 	"github.com/shopspring/decimal"
 )
@@ -16,6 +24,8 @@ type (
 		CallbackURL    string                 `json:"callback_url"`
 		TransactionRef string                 `json:"tx_ref"`
 		Customization  map[string]interface{} `json:"customization"`
+		// Subaccounts routes part of the payment to the given subaccounts.
+		Subaccounts []SubaccountSplit `json:"subaccounts,omitempty"`
 	}
 
 	PaymentResponse struct {
@@ -27,11 +37,40 @@ type (
 	}
 
 	VerifyResponse struct {
-		Message string `json:"message"`
-		Status  string `json:"status"`
-		Data    struct {
-			Charge float64 `json:"charge"`
-		}
+		Message string     `json:"message"`
+		Status  string     `json:"status"`
+		Data    VerifyData `json:"data"`
+	}
+
+	// VerifyData is the transaction returned by the verify endpoint.
+	VerifyData struct {
+		FirstName string          `json:"first_name"`
+		LastName  string          `json:"last_name"`
+		Email     string          `json:"email"`
+		Currency  string          `json:"currency"`
+		Amount    decimal.Decimal `json:"amount"`
+		// Charge is the fee Chapa took from the amount.
+		Charge decimal.Decimal `json:"charge"`
+		// Mode is either "test" or "live".
+		Mode string `json:"mode"`
+		// Method is the payment method the customer used, e.g. "telebirr".
+		Method string            `json:"method"`
+		Type   string            `json:"type"`
+		Status TransactionStatus `json:"status"`
+		// Reference is Chapa's reference for the transaction.
+		Reference string `json:"reference"`
+		// TxRef is the merchant's transaction reference sent with the payment request.
+		TxRef         string                 `json:"tx_ref"`
+		Customization Customization          `json:"customization"`
+		Meta          map[string]interface{} `json:"meta"`
+		CreatedAt     time.Time              `json:"created_at"`
+		UpdatedAt     time.Time              `json:"updated_at"`
+	}
+
+	Customization struct {
+		Title       string `json:"title"`
+		Description string `json:"description"`
+		Logo        string `json:"logo"`
 	}
 
 	// BankTransfer is an object used in bank transfer.
@@ -58,6 +97,61 @@ type (
 		Data    string `json:"data"`
 	}
 
+	// Transfer is a payout to a bank account or mobile wallet as reported by Chapa.
+	Transfer struct {
+		AccountName   string `json:"account_name"`
+		AccountNumber string `json:"account_number"`
+		Mobile        string `json:"mobile"`
+		Currency      string `json:"currency"`
+		// Amount is the amount sent to the recipient.
+		Amount decimal.Decimal `json:"amount"`
+		// Charge is the fee Chapa took for the transfer.
+		Charge decimal.Decimal `json:"charge"`
+		// Mode is either "test" or "live".
+		Mode           string `json:"mode"`
+		TransferMethod string `json:"transfer_method"`
+		Narration      string `json:"narration"`
+		// ChapaReference is Chapa's own identifier of the transfer.
+		ChapaReference string `json:"chapa_transfer_id"`
+		BankCode       string `json:"bank_code"`
+		BankName       string `json:"bank_name"`
+		// CrossPartyReference is the reference assigned by the receiving bank.
+		CrossPartyReference string         `json:"cross_party_reference"`
+		Status              TransferStatus `json:"status"`
+		// FailureReason explains why a failed or reversed transfer did not go through.
+		FailureReason string `json:"failure_reason"`
+		// Reference is the merchant's reference sent with the transfer.
+		Reference string    `json:"tx_ref"`
+		CreatedAt time.Time `json:"created_at"`
+		UpdatedAt time.Time `json:"updated_at"`
+	}
+
+	// TransferFilter narrows the transfers returned by ListTransfers.
+	// Zero fields are not sent.
+	TransferFilter struct {
+		Status TransferStatus
+		// From and To bound the creation date of the transfers, inclusive.
+		From time.Time
+		To   time.Time
+		// BatchID limits the transfers to the items of a bulk transfer.
+		BatchID int
+		PerPage int
+		Page    int
+	}
+
+	TransfersResponse struct {
+		Message    string     `json:"message"`
+		Status     string     `json:"status"`
+		Data       []Transfer `json:"data"`
+		Pagination Pagination `json:"meta"`
+	}
+
+	VerifyTransferResponse struct {
+		Message string   `json:"message"`
+		Status  string   `json:"status"`
+		Data    Transfer `json:"data"`
+	}
+
 	Transaction struct {
 		Status        TransactionStatus `json:"status"`
 		RefID         string            `json:"ref_id"`
@@ -97,6 +191,41 @@ type (
 		Status  string          `json:"status"`
 		Data    TransactionList `json:"data"`
 	}
+
+	// TransactionLog is one step of the history Chapa keeps for a
+	// transaction, such as the checkout being opened or an OTP being sent.
+	TransactionLog struct {
+		Item      string    `json:"item"`
+		Message   string    `json:"message"`
+		Type      string    `json:"type"`
+		CreatedAt time.Time `json:"created_at"`
+		UpdatedAt time.Time `json:"updated_at"`
+	}
+
+	TransactionLogsResponse struct {
+		Message string `json:"message"`
+		Status  string `json:"status"`
+		// Data is ordered from the oldest event to the newest.
+		Data []TransactionLog `json:"data"`
+	}
+
+	// TransactionFilter narrows the transactions returned by ListTransactions
+	// and Transactions. Zero fields are not sent.
+	TransactionFilter struct {
+		// From and To bound the creation date of the transactions, inclusive.
+		From     time.Time
+		To       time.Time
+		Status   TransactionStatus
+		Currency string
+		// PaymentMethod is the method the customer paid with, e.g. "telebirr".
+		PaymentMethod string
+		// Reference matches the merchant tx_ref or the Chapa reference.
+		Reference string
+		// Customer matches the email of the customer.
+		Customer string
+		PerPage  int
+		Page     int
+	}
 	CheckoutForm struct {
 		Amount   decimal.Decimal `json:"amount"`
 		Currency string          `json:"currency"`
@@ -104,6 +233,8 @@ type (
 
 	TransactionStatus string
 
+	TransferStatus string
+
 	Currency string
 
 	Bank struct {
@@ -124,6 +255,118 @@ type (
 		Data    []Bank `json:"data"`
 	}
 
+	// PaymentMethod is a mobile wallet that can be charged directly.
+	PaymentMethod string
+
+	// AuthType is the action that completes a direct charge.
+	AuthType string
+
+	// DirectChargeRequest charges a customer's mobile wallet without
+	// redirecting them to the Chapa checkout.
+	DirectChargeRequest struct {
+		Amount    decimal.Decimal `json:"amount"`
+		Currency  string          `json:"currency"`
+		Email     string          `json:"email,omitempty"`
+		FirstName string          `json:"first_name,omitempty"`
+		LastName  string          `json:"last_name,omitempty"`
+		// Mobile is the wallet number, e.g. 0912345678.
+		Mobile         string `json:"mobile"`
+		TransactionRef string `json:"tx_ref"`
+	}
+
+	DirectChargeResponse struct {
+		Message string           `json:"message"`
+		Status  string           `json:"status"`
+		Data    DirectChargeData `json:"data"`
+	}
+
+	// DirectChargeData is a pending direct charge and how to complete it.
+	DirectChargeData struct {
+		// AuthType is USSDAuth when the customer confirms the charge on their
+		// phone, and OTPAuth when the code sent to them must be passed to
+		// AuthorizeDirectCharge.
+		AuthType  AuthType               `json:"auth_type"`
+		Reference string                 `json:"reference"`
+		Meta      map[string]interface{} `json:"meta,omitempty"`
+	}
+
+	AuthorizeDirectChargeResponse struct {
+		Message string `json:"message"`
+		Status  string `json:"status"`
+		Data    struct {
+			Reference string            `json:"reference"`
+			Status    TransactionStatus `json:"status"`
+		} `json:"data"`
+	}
+
+	// RefundRequest refunds a paid transaction. A zero Amount refunds what is
+	// left of the transaction after earlier refunds; otherwise Amount is
+	// refunded and the rest kept.
+	RefundRequest struct {
+		Amount decimal.Decimal `json:"amount"`
+		Reason string          `json:"reason,omitempty"`
+		// Reference identifies the refund. Refund fills it in when empty.
+		Reference string                 `json:"reference"`
+		Meta      map[string]interface{} `json:"meta,omitempty"`
+	}
+
+	RefundResponse struct {
+		Message string `json:"message"`
+		Status  string `json:"status"`
+		Data    Refund `json:"data"`
+	}
+
+	// Refund is a refund as reported by Chapa.
+	Refund struct {
+		ChapaReference string          `json:"chapa_reference"`
+		Reference      string          `json:"reference"`
+		TxRef          string          `json:"tx_ref"`
+		Amount         decimal.Decimal `json:"amount"`
+		Currency       string          `json:"currency"`
+		Status         string          `json:"status"`
+		CreatedAt      time.Time       `json:"created_at"`
+	}
+
+	// SplitType says how the share of a subaccount is computed.
+	SplitType string
+
+	// Subaccount is a vendor account that receives part of split payments.
+	Subaccount struct {
+		// ID is assigned by Chapa and ignored when creating a subaccount.
+		ID            string    `json:"id,omitempty"`
+		BusinessName  string    `json:"business_name"`
+		AccountName   string    `json:"account_name"`
+		BankCode      string    `json:"bank_code"`
+		AccountNumber string    `json:"account_number"`
+		SplitType     SplitType `json:"split_type"`
+		// SplitValue is a fraction between 0 and 1 for percentage splits,
+		// and an amount in the payment currency for flat splits.
+		SplitValue decimal.Decimal `json:"split_value"`
+	}
+
+	SubaccountResponse struct {
+		Message string `json:"message"`
+		Status  string `json:"status"`
+		Data    struct {
+			ID string `json:"subaccount_id"`
+		} `json:"data"`
+	}
+
+	SubaccountsResponse struct {
+		Message string       `json:"message"`
+		Status  string       `json:"status"`
+		Data    []Subaccount `json:"data"`
+	}
+
+	// SubaccountSplit assigns part of a payment to a subaccount. SplitType
+	// and SplitValue override the split the subaccount was created with;
+	// leave SplitType empty to keep it.
+	SubaccountSplit struct {
+		ID         string          `json:"id"`
+		SplitType  SplitType       `json:"split_type,omitempty"`
+		SplitValue decimal.Decimal `json:"split_value"`
+	}
+
 	BulkData struct {
 		AccountName   string `json:"account_name"`
 		AccountNumber string `json:"account_number"`
@@ -148,39 +391,478 @@ type (
 		Status  string                   `json:"status"`
 		Data    BulkTransferResponseData `json:"data"`
 	}
+
+	BulkTransferStatus string
+
+	// BulkTransferResult is the state of a bulk transfer and of each of its items.
+	BulkTransferResult struct {
+		ID     int
+		Status BulkTransferStatus
+		// Transfers holds the transfers of the batch in the order Chapa lists
+		// them, which need not follow BulkData; use Reference to match them up.
+		Transfers []Transfer
+	}
 )
 
 const (
 	FailedTransactionStatus  TransactionStatus = "failed"
 	PendingTransactionStatus TransactionStatus = "pending"
 	SuccessTransactionStatus TransactionStatus = "success"
-	ETB                      Currency          = "ETB"
-	USD                      Currency          = "USD"
+	// A reversed transaction was paid and the charge was later reversed.
+	ReversedTransactionStatus TransactionStatus = "reversed"
+	// A refunded transaction was paid and has been refunded in full.
+	RefundedTransactionStatus TransactionStatus = "refunded"
+	PendingTransferStatus     TransferStatus    = "pending"
+	SuccessTransferStatus     TransferStatus    = "success"
+	FailedTransferStatus      TransferStatus    = "failed"
+	ReversedTransferStatus    TransferStatus    = "reversed"
+	// A bulk transfer is pending while any item is pending, and partial when
+	// some items succeeded and others failed or were reversed.
+	PendingBulkTransferStatus BulkTransferStatus = "pending"
+	SuccessBulkTransferStatus BulkTransferStatus = "success"
+	FailedBulkTransferStatus  BulkTransferStatus = "failed"
+	PartialBulkTransferStatus BulkTransferStatus = "partial"
+	Telebirr                  PaymentMethod      = "telebirr"
+	Mpesa                     PaymentMethod      = "mpesa"
+	CBEBirr                   PaymentMethod      = "cbebirr"
+	AwashBirr                 PaymentMethod      = "awashbirr"
+	USSDAuth                  AuthType           = "ussd"
+	OTPAuth                   AuthType           = "otp"
+	FlatSplit                 SplitType          = "flat"
+	PercentageSplit           SplitType          = "percentage"
+	ETB                       Currency           = "ETB"
+	USD                       Currency           = "USD"
 )
 
 func (p PaymentRequest) Validate() error {
-	return validation.ValidateStruct(&p,
+	return newValidationError(validation.ValidateStruct(&p,
 		validation.Field(&p.TransactionRef, validation.Required.Error("transaction reference is required")),
 		validation.Field(&p.Currency, validation.Required.Error("currency is required")),
 		validation.Field(&p.Amount, validation.Required.Error("amount is required")),
+		validation.Field(&p.Subaccounts, validation.By(p.validateSplits)),
+	))
+}
+
+// validateSplits checks every split on its own, and that the flat splits
+// together do not exceed the amount of the payment.
+func (p PaymentRequest) validateSplits(interface{}) error {
+	errs := validation.Errors{}
+	flat := decimal.Zero
+	for i, split := range p.Subaccounts {
+		if err := split.validate(); err != nil {
+			errs[strconv.Itoa(i)] = err
+			continue
+		}
+
+		if split.SplitType == FlatSplit {
+			flat = flat.Add(split.SplitValue)
+			if flat.GreaterThan(p.Amount) {
+				errs[strconv.Itoa(i)] = validation.Errors{
+					"split_value": errors.New("flat splits must not exceed the payment amount"),
+				}
+			}
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+func (s SubaccountSplit) validate() error {
+	return validation.ValidateStruct(&s,
+		validation.Field(&s.ID, validation.Required.Error("subaccount id is required")),
+		validation.Field(&s.SplitType, validation.In(FlatSplit, PercentageSplit).Error("split type must be flat or percentage")),
+		validation.Field(&s.SplitValue, validation.When(s.SplitType != "", validation.By(splitValueRule(s.SplitType)))),
 	)
 }
 
+// MarshalJSON leaves out split_value when the split of the subaccount is
+// kept, so that Chapa does not read it as a zero split.
+func (s SubaccountSplit) MarshalJSON() ([]byte, error) {
+	type subaccountSplit SubaccountSplit
+	if s.SplitType != "" {
+		return json.Marshal(subaccountSplit(s))
+	}
+	return json.Marshal(struct {
+		ID string `json:"id"`
+	}{ID: s.ID})
+}
+
+func (s Subaccount) Validate() error {
+	return newValidationError(validation.ValidateStruct(&s,
+		validation.Field(&s.BusinessName, validation.Required.Error("business name is required")),
+		validation.Field(&s.AccountName, validation.Required.Error("account name is required")),
+		validation.Field(&s.BankCode, validation.Required.Error("bank code is required")),
+		validation.Field(&s.AccountNumber, validation.Required.Error("account number is required")),
+		validation.Field(&s.SplitType,
+			validation.Required.Error("split type is required"),
+			validation.In(FlatSplit, PercentageSplit).Error("split type must be flat or percentage")),
+		validation.Field(&s.SplitValue, validation.By(splitValueRule(s.SplitType))),
+	))
+}
+
+// splitValueRule checks that a split value is positive, and at most 1 for
+// percentage splits.
+func splitValueRule(splitType SplitType) validation.RuleFunc {
+	return func(value interface{}) error {
+		splitValue, _ := value.(decimal.Decimal)
+		if !splitValue.IsPositive() {
+			return errors.New("split value must be greater than 0")
+		}
+		if splitType == PercentageSplit && splitValue.GreaterThan(decimal.NewFromInt(1)) {
+			return errors.New("percentage split must be between 0 and 1")
+		}
+		return nil
+	}
+}
+
 func (t BankTransfer) Validate() error {
-	return validation.ValidateStruct(&t,
+	return newValidationError(validation.ValidateStruct(&t,
 		validation.Field(&t.AccountName, validation.Required.Error("account name is required")),
 		validation.Field(&t.AccountNumber, validation.Required.Error("account number is required")),
 		validation.Field(&t.Amount, validation.Required.Error("amount is required")),
 		validation.Field(&t.Currency, validation.Required.Error("currency is required")),
 		validation.Field(&t.Reference, validation.Required.Error("reference is required")),
 		validation.Field(&t.BankCode, validation.Required.Error("bank code is required")),
-	)
+	))
+}
+
+func (d BulkData) Validate() error {
+	return newValidationError(validation.ValidateStruct(&d,
+		validation.Field(&d.AccountName, validation.Required.Error("account name is required")),
+		validation.Field(&d.AccountNumber, validation.Required.Error("account number is required")),
+		validation.Field(&d.Amount, validation.Required.Error("amount is required")),
+		validation.Field(&d.Reference, validation.Required.Error("reference is required")),
+		validation.Field(&d.BankCode, validation.Required.Error("bank code is required")),
+	))
+}
+
+// references returns the transfer reference of every item in the batch.
+func (t BulkTransferRequest) references() []string {
+	references := make([]string, 0, len(t.BulkData))
+	for _, item := range t.BulkData {
+		references = append(references, item.Reference)
+	}
+	return references
+}
+
+// Failed returns the items that failed or were reversed.
+func (r BulkTransferResult) Failed() []Transfer {
+	var failed []Transfer
+	for _, transfer := range r.Transfers {
+		if transfer.Status == FailedTransferStatus || transfer.Status == ReversedTransferStatus {
+			failed = append(failed, transfer)
+		}
+	}
+	return failed
+}
+
+// FailedBulkData returns the failed items as BulkData, ready to be sent again
+// in a new bulk transfer. They keep their original references, which Chapa
+// and the idempotency guard treat as used; assign new ones before resending.
+func (r BulkTransferResult) FailedBulkData() []BulkData {
+	failed := r.Failed()
+	bulkData := make([]BulkData, 0, len(failed))
+	for _, transfer := range failed {
+		bulkData = append(bulkData, BulkData{
+			AccountName:   transfer.AccountName,
+			AccountNumber: transfer.AccountNumber,
+			Amount:        transfer.Amount.IntPart(),
+			Reference:     transfer.Reference,
+			BankCode:      transfer.BankCode,
+		})
+	}
+	return bulkData
+}
+
+// bulkTransferStatus derives the status of a batch from its items.
+func bulkTransferStatus(transfers []Transfer) BulkTransferStatus {
+	if len(transfers) == 0 {
+		return PendingBulkTransferStatus
+	}
+
+	var succeeded, failed int
+	for _, transfer := range transfers {
+		switch transfer.Status {
+		case SuccessTransferStatus:
+			succeeded++
+		case FailedTransferStatus, ReversedTransferStatus:
+			failed++
+		default:
+			return PendingBulkTransferStatus
+		}
+	}
+
+	switch {
+	case failed == 0:
+		return SuccessBulkTransferStatus
+	case succeeded == 0:
+		return FailedBulkTransferStatus
+	default:
+		return PartialBulkTransferStatus
+	}
 }
 
 func (t BulkTransferRequest) Validate() error {
-	return validation.ValidateStruct(&t,
+	return newValidationError(validation.ValidateStruct(&t,
 		validation.Field(&t.Title, validation.Required.Error("title of the bulk transfer is required")),
 		validation.Field(&t.Currency, validation.Required.Error("currency is required")),
 		validation.Field(&t.BulkData, validation.NilOrNotEmpty.Error("at least one account is required")),
-	)
+	))
+}
+
+func (d *VerifyData) UnmarshalJSON(data []byte) error {
+	type verifyData VerifyData
+	aux := struct {
+		*verifyData
+		CreatedAt string `json:"created_at"`
+		UpdatedAt string `json:"updated_at"`
+	}{verifyData: (*verifyData)(d)}
+
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	d.CreatedAt = lenientTime(aux.CreatedAt)
+	d.UpdatedAt = lenientTime(aux.UpdatedAt)
+	return nil
+}
+
+func (r DirectChargeRequest) Validate() error {
+	return newValidationError(validation.ValidateStruct(&r,
+		validation.Field(&r.TransactionRef, validation.Required.Error("transaction reference is required")),
+		validation.Field(&r.Currency, validation.Required.Error("currency is required")),
+		validation.Field(&r.Amount, validation.Required.Error("amount is required")),
+		validation.Field(&r.Mobile,
+			validation.Required.Error("mobile is required"),
+			validation.Match(mobilePattern).Error("mobile must be an Ethiopian mobile number")),
+	))
+}
+
+// validate checks that m is a wallet Chapa charges directly.
+func (m PaymentMethod) validate() error {
+	return newValidationError(validation.Errors{
+		"method": validation.Validate(m,
+			validation.Required.Error("payment method is required"),
+			validation.In(Telebirr, Mpesa, CBEBirr, AwashBirr).Error("payment method is not supported for direct charge")),
+	}.Filter())
+}
+
+func (r RefundRequest) Validate() error {
+	return newValidationError(validation.ValidateStruct(&r,
+		validation.Field(&r.Amount, validation.By(func(interface{}) error {
+			if r.Amount.IsNegative() {
+				return errors.New("amount must not be negative")
+			}
+			return nil
+		})),
+	))
+}
+
+// MarshalJSON leaves out a zero amount, which asks Chapa for a full refund.
+func (r RefundRequest) MarshalJSON() ([]byte, error) {
+	type refundRequest RefundRequest
+	aux := struct {
+		refundRequest
+		Amount *decimal.Decimal `json:"amount,omitempty"`
+	}{refundRequest: refundRequest(r)}
+
+	if !r.Amount.IsZero() {
+		aux.Amount = &r.Amount
+	}
+	return json.Marshal(aux)
+}
+
+func (r *Refund) UnmarshalJSON(data []byte) error {
+	type refund Refund
+	aux := struct {
+		*refund
+		CreatedAt string `json:"created_at"`
+	}{refund: (*refund)(r)}
+
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	r.CreatedAt = lenientTime(aux.CreatedAt)
+	return nil
+}
+
+func (s *Subaccount) UnmarshalJSON(data []byte) error {
+	type subaccount Subaccount
+	aux := struct {
+		*subaccount
+		// id and bank_code are numbers in some responses and strings in others
+		ID       json.RawMessage `json:"id"`
+		BankCode json.RawMessage `json:"bank_code"`
+	}{subaccount: (*subaccount)(s)}
+
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	s.ID = rawString(aux.ID)
+	s.BankCode = rawString(aux.BankCode)
+	return nil
+}
+
+func (l *TransactionLog) UnmarshalJSON(data []byte) error {
+	type transactionLog TransactionLog
+	aux := struct {
+		*transactionLog
+		Item      json.RawMessage `json:"item"`
+		CreatedAt string          `json:"created_at"`
+		UpdatedAt string          `json:"updated_at"`
+	}{transactionLog: (*transactionLog)(l)}
+
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	l.Item = rawString(aux.Item)
+
+	l.CreatedAt = lenientTime(aux.CreatedAt)
+	l.UpdatedAt = lenientTime(aux.UpdatedAt)
+	return nil
+}
+
+func (t *Transfer) UnmarshalJSON(data []byte) error {
+	type transfer Transfer
+	aux := struct {
+		*transfer
+		// bank_code is a number in some responses and a string in others
+		BankCode        json.RawMessage `json:"bank_code"`
+		LegacyReference string          `json:"reference"`
+		CreatedAt       string          `json:"created_at"`
+		UpdatedAt       string          `json:"updated_at"`
+	}{transfer: (*transfer)(t)}
+
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	t.BankCode = rawString(aux.BankCode)
+	if t.Reference == "" {
+		t.Reference = aux.LegacyReference
+	}
+
+	t.CreatedAt = lenientTime(aux.CreatedAt)
+	t.UpdatedAt = lenientTime(aux.UpdatedAt)
+	return nil
+}
+
+func (f *TransactionFilter) values() url.Values {
+	values := url.Values{}
+	if f == nil {
+		return values
+	}
+
+	if !f.From.IsZero() {
+		values.Set("from", f.From.Format(dateLayout))
+	}
+	if !f.To.IsZero() {
+		values.Set("to", f.To.Format(dateLayout))
+	}
+	if f.Status != "" {
+		values.Set("status", string(f.Status))
+	}
+	if f.Currency != "" {
+		values.Set("currency", f.Currency)
+	}
+	if f.PaymentMethod != "" {
+		values.Set("payment_method", f.PaymentMethod)
+	}
+	if f.Reference != "" {
+		values.Set("reference", f.Reference)
+	}
+	if f.Customer != "" {
+		values.Set("customer", f.Customer)
+	}
+	if f.PerPage > 0 {
+		values.Set("per_page", strconv.Itoa(f.PerPage))
+	}
+	if f.Page > 0 {
+		values.Set("page", strconv.Itoa(f.Page))
+	}
+
+	return values
+}
+
+func (f *TransferFilter) values() url.Values {
+	values := url.Values{}
+	if f == nil {
+		return values
+	}
+
+	if f.Status != "" {
+		values.Set("status", string(f.Status))
+	}
+	if !f.From.IsZero() {
+		values.Set("from", f.From.Format(dateLayout))
+	}
+	if !f.To.IsZero() {
+		values.Set("to", f.To.Format(dateLayout))
+	}
+	if f.BatchID > 0 {
+		values.Set("batch_id", strconv.Itoa(f.BatchID))
+	}
+	if f.PerPage > 0 {
+		values.Set("per_page", strconv.Itoa(f.PerPage))
+	}
+	if f.Page > 0 {
+		values.Set("page", strconv.Itoa(f.Page))
+	}
+
+	return values
+}
+
+// rawString returns a JSON string or number as a string. Null yields "".
+func rawString(raw json.RawMessage) string {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+	if string(raw) == "null" {
+		return ""
+	}
+	return string(raw)
+}
+
+// mobilePattern matches Ethiopian mobile numbers, with or without the
+// country code.
+var mobilePattern = regexp.MustCompile(`^(\+?251|0)[79][0-9]{8}$`)
+
+// dateLayout is the date format of Chapa query filters.
+const dateLayout = "2006-01-02"
+
+// timeLayouts are the timestamp formats found in Chapa responses.
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+// ParseTime parses a timestamp in any of the formats found in Chapa payloads.
+// An empty value yields the zero time.
+func ParseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("chapa: cannot parse time %q", value)
+}
+
+// lenientTime parses value with ParseTime, yielding the zero time for a format
+// it does not know so that a new timestamp format does not fail the payload.
+func lenientTime(value string) time.Time {
+	t, _ := ParseTime(value)
+	return t
 }
@@ -0,0 +1,240 @@
+package chapa
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifyResponse(t *testing.T) {
+	t.Run("decodes the full verify payload", func(t *testing.T) {
+		body := `{
+			"message": "Payment details",
+			"status": "success",
+			"data": {
+				"first_name": "Bilen",
+				"last_name": "Gizachew",
+				"email": "abebech_bekele@gmail.com",
+				"currency": "ETB",
+				"amount": 100,
+				"charge": "3.50",
+				"mode": "test",
+				"method": "telebirr",
+				"type": "API",
+				"status": "success",
+				"reference": "6jnheVKQEmy",
+				"tx_ref": "chewatatest-6669",
+				"customization": {"title": "Payment", "description": "I love online payments", "logo": null},
+				"meta": {"invoice": "INV-1"},
+				"created_at": "2023-02-02T07:05:23.000000Z",
+				"updated_at": "2023-02-02 07:06:00"
+			}
+		}`
+
+		var response VerifyResponse
+		assert.NoError(t, json.Unmarshal([]byte(body), &response))
+
+		data := response.Data
+		assert.Equal(t, "Bilen", data.FirstName)
+		assert.True(t, decimal.NewFromInt(100).Equal(data.Amount))
+		assert.True(t, decimal.RequireFromString("3.5").Equal(data.Charge))
+		assert.Equal(t, SuccessTransactionStatus, data.Status)
+		assert.Equal(t, "chewatatest-6669", data.TxRef)
+		assert.Equal(t, "telebirr", data.Method)
+		assert.Equal(t, "Payment", data.Customization.Title)
+		assert.Empty(t, data.Customization.Logo)
+		assert.Equal(t, "INV-1", data.Meta["invoice"])
+		assert.Equal(t, time.Date(2023, 2, 2, 7, 5, 23, 0, time.UTC), data.CreatedAt)
+		assert.Equal(t, time.Date(2023, 2, 2, 7, 6, 0, 0, time.UTC), data.UpdatedAt)
+	})
+
+	t.Run("tolerates missing timestamps", func(t *testing.T) {
+		var response VerifyResponse
+		assert.NoError(t, json.Unmarshal([]byte(`{"status":"success","data":{"status":"pending"}}`), &response))
+		assert.Equal(t, PendingTransactionStatus, response.Data.Status)
+		assert.True(t, response.Data.CreatedAt.IsZero())
+	})
+
+	t.Run("decodes the rest of the payload past an unexpected timestamp format", func(t *testing.T) {
+		var response VerifyResponse
+		body := `{"data":{"status":"success","tx_ref":"tx-1","created_at":"Thu, 02 Feb 2023 07:05:23 GMT","updated_at":"2023-02-02 07:06:00"}}`
+		assert.NoError(t, json.Unmarshal([]byte(body), &response))
+		assert.Equal(t, SuccessTransactionStatus, response.Data.Status)
+		assert.Equal(t, "tx-1", response.Data.TxRef)
+		assert.True(t, response.Data.CreatedAt.IsZero())
+		assert.Equal(t, time.Date(2023, 2, 2, 7, 6, 0, 0, time.UTC), response.Data.UpdatedAt)
+	})
+}
+
+func TestTransfer(t *testing.T) {
+	t.Run("decodes the verify transfer payload", func(t *testing.T) {
+		body := `{
+			"message": "Transfer details",
+			"status": "success",
+			"data": {
+				"account_name": "Israel Goytom",
+				"account_number": "32423423",
+				"mobile": null,
+				"currency": "ETB",
+				"amount": 100,
+				"charge": 0.5,
+				"mode": "live",
+				"transfer_method": "bank",
+				"narration": null,
+				"chapa_transfer_id": "4a1b6f4d-7c4e-4c3a-9f0b-1d0a5c0e2f11",
+				"bank_code": 128,
+				"bank_name": "Bunna Bank",
+				"cross_party_reference": null,
+				"status": "success",
+				"tx_ref": "6542f55a0e13e",
+				"created_at": "2023-11-02T01:09:30.000000Z",
+				"updated_at": "2023-11-02T01:09:30.000000Z"
+			}
+		}`
+
+		var response VerifyTransferResponse
+		assert.NoError(t, json.Unmarshal([]byte(body), &response))
+
+		transfer := response.Data
+		assert.Equal(t, "Israel Goytom", transfer.AccountName)
+		assert.Equal(t, "128", transfer.BankCode)
+		assert.Equal(t, "Bunna Bank", transfer.BankName)
+		assert.True(t, decimal.NewFromInt(100).Equal(transfer.Amount))
+		assert.True(t, decimal.RequireFromString("0.5").Equal(transfer.Charge))
+		assert.Equal(t, SuccessTransferStatus, transfer.Status)
+		assert.Equal(t, "6542f55a0e13e", transfer.Reference)
+		assert.Empty(t, transfer.Mobile)
+		assert.Equal(t, time.Date(2023, 11, 2, 1, 9, 30, 0, time.UTC), transfer.CreatedAt)
+	})
+
+	t.Run("accepts string bank codes and reference key", func(t *testing.T) {
+		var transfer Transfer
+		assert.NoError(t, json.Unmarshal([]byte(`{"bank_code":"946","reference":"ref-1","status":"pending"}`), &transfer))
+		assert.Equal(t, "946", transfer.BankCode)
+		assert.Equal(t, "ref-1", transfer.Reference)
+		assert.Equal(t, PendingTransferStatus, transfer.Status)
+	})
+
+	t.Run("tolerates an unexpected timestamp format", func(t *testing.T) {
+		var transfer Transfer
+		assert.NoError(t, json.Unmarshal([]byte(`{"status":"success","created_at":"1698887370"}`), &transfer))
+		assert.Equal(t, SuccessTransferStatus, transfer.Status)
+		assert.True(t, transfer.CreatedAt.IsZero())
+	})
+}
+
+func TestBulkTransferStatus(t *testing.T) {
+	transfers := func(statuses ...TransferStatus) []Transfer {
+		result := make([]Transfer, 0, len(statuses))
+		for _, status := range statuses {
+			result = append(result, Transfer{Status: status})
+		}
+		return result
+	}
+
+	assert.Equal(t, PendingBulkTransferStatus, bulkTransferStatus(nil))
+	assert.Equal(t, PendingBulkTransferStatus, bulkTransferStatus(transfers(SuccessTransferStatus, PendingTransferStatus)))
+	assert.Equal(t, SuccessBulkTransferStatus, bulkTransferStatus(transfers(SuccessTransferStatus, SuccessTransferStatus)))
+	assert.Equal(t, FailedBulkTransferStatus, bulkTransferStatus(transfers(FailedTransferStatus, ReversedTransferStatus)))
+	assert.Equal(t, PartialBulkTransferStatus, bulkTransferStatus(transfers(SuccessTransferStatus, FailedTransferStatus)))
+}
+
+func TestSubaccounts(t *testing.T) {
+	payment := func(splits ...SubaccountSplit) PaymentRequest {
+		return PaymentRequest{
+			Amount:         decimal.NewFromInt(100),
+			Currency:       "ETB",
+			TransactionRef: "tx-1",
+			Subaccounts:    splits,
+		}
+	}
+
+	fields := func(err error) map[string]string {
+		var validationErr *ValidationError
+		if !errors.As(err, &validationErr) {
+			return nil
+		}
+		return validationErr.Fields
+	}
+
+	t.Run("accepts valid splits", func(t *testing.T) {
+		assert.NoError(t, payment(
+			SubaccountSplit{ID: "sub-1"},
+			SubaccountSplit{ID: "sub-2", SplitType: PercentageSplit, SplitValue: decimal.RequireFromString("0.2")},
+			SubaccountSplit{ID: "sub-3", SplitType: FlatSplit, SplitValue: decimal.NewFromInt(100)},
+		).Validate())
+	})
+
+	t.Run("rejects percentages outside 0 to 1", func(t *testing.T) {
+		err := payment(
+			SubaccountSplit{ID: "sub-1", SplitType: PercentageSplit, SplitValue: decimal.RequireFromString("1.5")},
+			SubaccountSplit{ID: "sub-2", SplitType: PercentageSplit, SplitValue: decimal.NewFromInt(-1)},
+		).Validate()
+		assert.Equal(t, map[string]string{
+			"subaccounts[0].split_value": "percentage split must be between 0 and 1",
+			"subaccounts[1].split_value": "split value must be greater than 0",
+		}, fields(err))
+	})
+
+	t.Run("rejects flat splits above the amount", func(t *testing.T) {
+		err := payment(
+			SubaccountSplit{ID: "sub-1", SplitType: FlatSplit, SplitValue: decimal.NewFromInt(60)},
+			SubaccountSplit{ID: "sub-2", SplitType: FlatSplit, SplitValue: decimal.NewFromInt(50)},
+		).Validate()
+		assert.Equal(t, map[string]string{
+			"subaccounts[1].split_value": "flat splits must not exceed the payment amount",
+		}, fields(err))
+	})
+
+	t.Run("rejects incomplete splits", func(t *testing.T) {
+		err := payment(SubaccountSplit{SplitType: "half"}).Validate()
+		assert.Equal(t, map[string]string{
+			"subaccounts[0].id":          "subaccount id is required",
+			"subaccounts[0].split_type":  "split type must be flat or percentage",
+			"subaccounts[0].split_value": "split value must be greater than 0",
+		}, fields(err))
+	})
+
+	t.Run("validates new subaccounts", func(t *testing.T) {
+		err := Subaccount{
+			BusinessName:  "Abebe Souq",
+			AccountName:   "Abebe Bikila",
+			BankCode:      "946",
+			AccountNumber: "1000212482106",
+			SplitType:     PercentageSplit,
+			SplitValue:    decimal.NewFromInt(2),
+		}.Validate()
+		assert.Equal(t, map[string]string{"split_value": "percentage split must be between 0 and 1"}, fields(err))
+
+		err = Subaccount{}.Validate()
+		assert.Len(t, fields(err), 6)
+	})
+
+	t.Run("omits the split value of default splits", func(t *testing.T) {
+		data, err := json.Marshal([]SubaccountSplit{
+			{ID: "sub-1"},
+			{ID: "sub-2", SplitType: FlatSplit, SplitValue: decimal.NewFromInt(25)},
+		})
+		assert.NoError(t, err)
+		assert.JSONEq(t, `[{"id":"sub-1"},{"id":"sub-2","split_type":"flat","split_value":"25"}]`, string(data))
+
+		data, err = json.Marshal(PaymentRequest{})
+		assert.NoError(t, err)
+		assert.NotContains(t, string(data), "subaccounts")
+	})
+
+	t.Run("decodes listed subaccounts", func(t *testing.T) {
+		var response SubaccountsResponse
+		assert.NoError(t, json.Unmarshal([]byte(`{"status":"success","data":[
+			{"id":12,"business_name":"Abebe Souq","bank_code":946,"account_number":"1000212482106","split_type":"flat","split_value":25}
+		]}`), &response))
+		assert.Equal(t, "12", response.Data[0].ID)
+		assert.Equal(t, "946", response.Data[0].BankCode)
+		assert.Equal(t, FlatSplit, response.Data[0].SplitType)
+		assert.True(t, decimal.NewFromInt(25).Equal(response.Data[0].SplitValue))
+	})
+}
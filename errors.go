@@ -0,0 +1,199 @@
+package chapa
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+// Sentinel errors matched by *APIError through errors.Is.
+var (
+	ErrBadRequest   = errors.New("chapa: bad request")
+	ErrUnauthorized = errors.New("chapa: unauthorized")
+	ErrNotFound     = errors.New("chapa: not found")
+	ErrRateLimited  = errors.New("chapa: rate limited")
+	ErrServer       = errors.New("chapa: server error")
+)
+
+// APIError is returned when Chapa responds with a non-2xx status code.
+type APIError struct {
+	// StatusCode is the HTTP status code of the response.
+	StatusCode int
+	// Status is the status field of the Chapa response body, usually "failed".
+	Status string
+	// Message is the message Chapa returned, if it was a plain string.
+	Message string
+	// Fields holds per-field validation messages, keyed by request field name.
+	Fields map[string][]string
+	// Header holds the response headers, e.g. Retry-After.
+	Header http.Header
+	// Body is the raw response body.
+	Body []byte
+}
+
+func (e *APIError) Error() string {
+	message := e.Message
+	if message == "" && len(e.Fields) > 0 {
+		keys := make([]string, 0, len(e.Fields))
+		for key := range e.Fields {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		parts := make([]string, 0, len(keys))
+		for _, key := range keys {
+			parts = append(parts, fmt.Sprintf("%s: %s", key, strings.Join(e.Fields[key], ", ")))
+		}
+		message = strings.Join(parts, "; ")
+	}
+	if message == "" {
+		message = http.StatusText(e.StatusCode)
+	}
+
+	return fmt.Sprintf("chapa: %d %s", e.StatusCode, message)
+}
+
+// Is reports whether the error matches one of the package sentinel errors.
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrBadRequest:
+		return e.StatusCode == http.StatusBadRequest || e.StatusCode == http.StatusUnprocessableEntity
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrRateLimited:
+		return e.StatusCode == http.StatusTooManyRequests
+	case ErrServer:
+		return e.StatusCode >= http.StatusInternalServerError
+	}
+	return false
+}
+
+// ValidationError is returned when a request fails validation before it is sent.
+type ValidationError struct {
+	// Fields maps the JSON name of each invalid field to its message. Nested
+	// fields are joined with dots and list items carry their index, e.g.
+	// "bulk_data[0].amount".
+	Fields map[string]string
+}
+
+func (e *ValidationError) Error() string {
+	keys := make([]string, 0, len(e.Fields))
+	for key := range e.Fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys))
+	for _, key := range keys {
+		parts = append(parts, fmt.Sprintf("%s: %s", key, e.Fields[key]))
+	}
+
+	return "invalid input " + strings.Join(parts, "; ")
+}
+
+// newValidationError converts the result of an ozzo validation into a
+// *ValidationError. Nil and internal errors are returned unchanged.
+func newValidationError(err error) error {
+	var errs validation.Errors
+	if !errors.As(err, &errs) {
+		return err
+	}
+
+	fields := make(map[string]string)
+	flattenValidationErrors(fields, "", errs)
+
+	return &ValidationError{Fields: fields}
+}
+
+func flattenValidationErrors(fields map[string]string, prefix string, errs map[string]error) {
+	for key, err := range errs {
+		path := key
+		if _, convErr := strconv.Atoi(key); convErr == nil {
+			path = prefix + "[" + key + "]"
+		} else if prefix != "" {
+			path = prefix + "." + key
+		}
+
+		var nested validation.Errors
+		var validationErr *ValidationError
+		switch {
+		case errors.As(err, &nested):
+			flattenValidationErrors(fields, path, nested)
+		case errors.As(err, &validationErr):
+			for field, message := range validationErr.Fields {
+				if strings.HasPrefix(field, "[") {
+					fields[path+field] = message
+				} else {
+					fields[path+"."+field] = message
+				}
+			}
+		default:
+			fields[path] = err.Error()
+		}
+	}
+}
+
+// newAPIError builds an *APIError from a failed response. Chapa reports
+// validation failures either as an object in "message" or under "errors".
+func newAPIError(statusCode int, header http.Header, body []byte) *APIError {
+	apiErr := &APIError{
+		StatusCode: statusCode,
+		Header:     header,
+		Body:       body,
+	}
+
+	var payload struct {
+		Status  string          `json:"status"`
+		Message json.RawMessage `json:"message"`
+		Errors  json.RawMessage `json:"errors"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return apiErr
+	}
+
+	apiErr.Status = payload.Status
+	if err := json.Unmarshal(payload.Message, &apiErr.Message); err != nil {
+		apiErr.Fields = decodeFieldErrors(payload.Message)
+	}
+	if fields := decodeFieldErrors(payload.Errors); len(fields) > 0 {
+		apiErr.Fields = fields
+	}
+
+	return apiErr
+}
+
+// decodeFieldErrors decodes a field to message(s) object, accepting either a
+// single string or a list of strings per field.
+func decodeFieldErrors(raw json.RawMessage) map[string][]string {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil || len(fields) == 0 {
+		return nil
+	}
+
+	result := make(map[string][]string, len(fields))
+	for key, value := range fields {
+		var messages []string
+		if err := json.Unmarshal(value, &messages); err == nil {
+			result[key] = messages
+			continue
+		}
+
+		var message string
+		if err := json.Unmarshal(value, &message); err == nil {
+			result[key] = []string{message}
+			continue
+		}
+
+		result[key] = []string{string(value)}
+	}
+
+	return result
+}
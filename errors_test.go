@@ -0,0 +1,117 @@
+package chapa
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPIError(t *testing.T) {
+	respond := func(status int, body string) API {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(status)
+			_, _ = w.Write([]byte(body))
+		}))
+		t.Cleanup(server.Close)
+
+		return NewClient("key", WithBaseURL(server.URL))
+	}
+
+	t.Run("unauthorized response", func(t *testing.T) {
+		paymentProvider := respond(http.StatusUnauthorized, `{"message":"Invalid API Key","status":"failed","data":null}`)
+
+		response, err := paymentProvider.GetBanks()
+		assert.Nil(t, response)
+		assert.True(t, errors.Is(err, ErrUnauthorized))
+
+		var apiErr *APIError
+		assert.True(t, errors.As(err, &apiErr))
+		assert.Equal(t, http.StatusUnauthorized, apiErr.StatusCode)
+		assert.Equal(t, "failed", apiErr.Status)
+		assert.Equal(t, "Invalid API Key", apiErr.Message)
+		assert.Equal(t, "chapa: 401 Invalid API Key", apiErr.Error())
+	})
+
+	t.Run("validation response", func(t *testing.T) {
+		paymentProvider := respond(http.StatusBadRequest, `{"message":{"amount":["The amount field is required."],"currency":"The currency is invalid."},"status":"failed"}`)
+
+		_, err := paymentProvider.Verify("ref")
+		assert.True(t, errors.Is(err, ErrBadRequest))
+
+		var apiErr *APIError
+		assert.True(t, errors.As(err, &apiErr))
+		assert.Empty(t, apiErr.Message)
+		assert.Equal(t, []string{"The amount field is required."}, apiErr.Fields["amount"])
+		assert.Equal(t, []string{"The currency is invalid."}, apiErr.Fields["currency"])
+		assert.Equal(t, "chapa: 400 amount: The amount field is required.; currency: The currency is invalid.", apiErr.Error())
+	})
+
+	t.Run("non json server error", func(t *testing.T) {
+		paymentProvider := respond(http.StatusBadGateway, `<html>bad gateway</html>`)
+
+		_, err := paymentProvider.GetTransactions()
+		assert.True(t, errors.Is(err, ErrServer))
+		assert.False(t, errors.Is(err, ErrNotFound))
+
+		var apiErr *APIError
+		assert.True(t, errors.As(err, &apiErr))
+		assert.Equal(t, []byte(`<html>bad gateway</html>`), apiErr.Body)
+		assert.Equal(t, "chapa: 502 Bad Gateway", apiErr.Error())
+	})
+
+	t.Run("rate limited and not found", func(t *testing.T) {
+		_, err := respond(http.StatusTooManyRequests, `{}`).GetBanks()
+		assert.True(t, errors.Is(err, ErrRateLimited))
+
+		_, err = respond(http.StatusNotFound, `{"message":"Invalid transaction reference","status":"failed"}`).Verify("ref")
+		assert.True(t, errors.Is(err, ErrNotFound))
+	})
+}
+
+func TestValidationError(t *testing.T) {
+	t.Run("bank transfer reports missing fields", func(t *testing.T) {
+		err := BankTransfer{AccountNumber: "34264263", Amount: 10, Currency: "ETB"}.Validate()
+
+		var validationErr *ValidationError
+		assert.True(t, errors.As(err, &validationErr))
+		assert.Equal(t, map[string]string{
+			"account_name": "account name is required",
+			"reference":    "reference is required",
+			"bank_code":    "bank code is required",
+		}, validationErr.Fields)
+		assert.Contains(t, err.Error(), "invalid input")
+	})
+
+	t.Run("bulk transfer reports nested item fields", func(t *testing.T) {
+		err := BulkTransferRequest{
+			Currency: "ETB",
+			BulkData: []BulkData{
+				{AccountName: "Leul Abay Ejigu", AccountNumber: "1000212482106", Amount: 10, Reference: "ref-1", BankCode: "946"},
+				{AccountName: "Leul Abay Ejigu", Reference: "ref-2", BankCode: "946"},
+			},
+		}.Validate()
+
+		var validationErr *ValidationError
+		assert.True(t, errors.As(err, &validationErr))
+		assert.Equal(t, map[string]string{
+			"title":                       "title of the bulk transfer is required",
+			"bulk_data[1].account_number": "account number is required",
+			"bulk_data[1].amount":         "amount is required",
+		}, validationErr.Fields)
+	})
+
+	t.Run("valid request returns nil", func(t *testing.T) {
+		err := BankTransfer{
+			AccountName:   "Leul Abay Ejigu",
+			AccountNumber: "1000212482106",
+			Amount:        10,
+			Currency:      "ETB",
+			Reference:     "ref",
+			BankCode:      "946",
+		}.Validate()
+		assert.NoError(t, err)
+	})
+}
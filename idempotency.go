@@ -0,0 +1,323 @@
+package chapa
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrDuplicateTransfer is returned when a transfer reference is already known
+// to have been accepted by Chapa. The transfer must be verified, not re-sent.
+var ErrDuplicateTransfer = errors.New("chapa: transfer reference already used")
+
+// ErrTransferAlreadySent is matched by *TransferSentError.
+var ErrTransferAlreadySent = errors.New("chapa: transfer already sent")
+
+// TransferSentError is returned when a retried transfer is found at Chapa:
+// an earlier attempt of the same call went through, so the transfer was made
+// exactly once even though its response was lost.
+type TransferSentError struct {
+	// Reference is the reference found at Chapa.
+	Reference string
+	// Transfer is what Chapa reports about the transfer.
+	Transfer Transfer
+}
+
+func (e *TransferSentError) Error() string {
+	return fmt.Sprintf("%v: %s", ErrTransferAlreadySent, e.Reference)
+}
+
+func (e *TransferSentError) Unwrap() error {
+	return ErrTransferAlreadySent
+}
+
+// IdempotencyState is the state of a transfer reference in an IdempotencyStore.
+type IdempotencyState string
+
+const (
+	// IdempotencyInFlight marks a reference whose transfer is being sent.
+	IdempotencyInFlight IdempotencyState = "in_flight"
+	// IdempotencyPending marks a reference whose transfer was sent without a
+	// definitive answer, e.g. because the request timed out.
+	IdempotencyPending IdempotencyState = "pending"
+	// IdempotencySucceeded marks a reference whose transfer Chapa accepted.
+	IdempotencySucceeded IdempotencyState = "succeeded"
+)
+
+// IdempotencyStore records the state of transfer references so that a
+// reference is never sent twice. Implementations must be safe for concurrent use.
+type IdempotencyStore interface {
+	// LoadOrStore returns the state stored for reference if there is one.
+	// Otherwise it stores state and returns it with loaded set to false.
+	LoadOrStore(ctx context.Context, reference string, state IdempotencyState) (actual IdempotencyState, loaded bool, err error)
+	// CompareAndSwap sets the state of reference to state only if it is
+	// currently old, and reports whether it did.
+	CompareAndSwap(ctx context.Context, reference string, old, state IdempotencyState) (swapped bool, err error)
+	// Store sets the state of reference.
+	Store(ctx context.Context, reference string, state IdempotencyState) error
+	// Delete forgets reference, allowing it to be sent again.
+	Delete(ctx context.Context, reference string) error
+}
+
+// DefaultIdempotencyTTL is how long the store NewClient creates remembers
+// references that succeeded.
+const DefaultIdempotencyTTL = 24 * time.Hour
+
+// MemoryIdempotencyStore is an IdempotencyStore kept in process memory.
+//
+// References that succeeded are forgotten once their ttl has passed, so a
+// long-running client does not grow without bound; a reference re-sent after
+// that is no longer refused. References that are in flight or pending are
+// kept until they are settled.
+type MemoryIdempotencyStore struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	now     func() time.Time
+	sweepAt time.Time
+	entries map[string]idempotencyEntry
+}
+
+type idempotencyEntry struct {
+	state   IdempotencyState
+	expires time.Time
+}
+
+// NewMemoryIdempotencyStore creates an empty in-memory IdempotencyStore that
+// remembers succeeded references for ttl. A non-positive ttl keeps them for
+// the life of the store.
+func NewMemoryIdempotencyStore(ttl time.Duration) *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{
+		ttl:     ttl,
+		now:     time.Now,
+		entries: make(map[string]idempotencyEntry),
+	}
+}
+
+func (s *MemoryIdempotencyStore) LoadOrStore(_ context.Context, reference string, state IdempotencyState) (IdempotencyState, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if entry, ok := s.load(reference); ok {
+		return entry.state, true, nil
+	}
+
+	s.store(reference, state)
+	return state, false, nil
+}
+
+func (s *MemoryIdempotencyStore) CompareAndSwap(_ context.Context, reference string, old, state IdempotencyState) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if entry, ok := s.load(reference); !ok || entry.state != old {
+		return false, nil
+	}
+
+	s.store(reference, state)
+	return true, nil
+}
+
+func (s *MemoryIdempotencyStore) Store(_ context.Context, reference string, state IdempotencyState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.store(reference, state)
+	return nil
+}
+
+func (s *MemoryIdempotencyStore) Delete(_ context.Context, reference string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.entries, reference)
+	return nil
+}
+
+// load returns the live entry for reference. s.mu must be held.
+func (s *MemoryIdempotencyStore) load(reference string) (idempotencyEntry, bool) {
+	entry, ok := s.entries[reference]
+	if ok && s.expired(entry, s.now()) {
+		delete(s.entries, reference)
+		return idempotencyEntry{}, false
+	}
+
+	return entry, ok
+}
+
+// store sets the state of reference, sweeping expired entries at most once
+// per ttl. s.mu must be held.
+func (s *MemoryIdempotencyStore) store(reference string, state IdempotencyState) {
+	now := s.now()
+
+	entry := idempotencyEntry{state: state}
+	if state == IdempotencySucceeded && s.ttl > 0 {
+		entry.expires = now.Add(s.ttl)
+	}
+	s.entries[reference] = entry
+
+	if s.ttl > 0 && !now.Before(s.sweepAt) {
+		for key, entry := range s.entries {
+			if s.expired(entry, now) {
+				delete(s.entries, key)
+			}
+		}
+		s.sweepAt = now.Add(s.ttl)
+	}
+}
+
+func (s *MemoryIdempotencyStore) expired(entry idempotencyEntry, now time.Time) bool {
+	return !entry.expires.IsZero() && !now.Before(entry.expires)
+}
+
+// sendTransfer sends a transfer for the given references through send,
+// guarded by the idempotency store. References already accepted are refused,
+// and a reference left pending by an ambiguous failure is verified with Chapa
+// before it is sent again, both across calls and between retries.
+func (c *chapa) sendTransfer(ctx context.Context, references []string, send func() error) error {
+	retryable := transferRetryAllowed(ctx, references...)
+	if c.idempotency == nil {
+		return c.withRetry(ctx, retryable, send)
+	}
+
+	if err := c.reserveTransfers(ctx, references); err != nil {
+		return err
+	}
+
+	attempt := 0
+	// ambiguous is set once any attempt may have reached Chapa, after which
+	// only success or finding the transfer at Chapa can settle the outcome
+	ambiguous := false
+	err := c.withRetry(ctx, retryable, func() error {
+		attempt++
+		if attempt > 1 {
+			if err := c.checkTransfersUnknown(ctx, references); err != nil {
+				return err
+			}
+		}
+
+		err := send()
+		if err != nil && isAmbiguous(err) {
+			ambiguous = true
+		}
+		return err
+	})
+
+	switch {
+	case err == nil:
+		c.settleTransfers(ctx, references, IdempotencySucceeded)
+	case errors.Is(err, ErrTransferAlreadySent):
+		// found at Chapa while retrying, so the whole request went through
+		c.settleTransfers(ctx, references, IdempotencySucceeded)
+	case ambiguous || isAmbiguous(err):
+		c.logger.Printf("warning transfer outcome unknown for references %v: %v", references, err)
+		c.settleTransfers(ctx, references, IdempotencyPending)
+	default:
+		c.settleTransfers(ctx, references, "")
+	}
+
+	return err
+}
+
+// reserveTransfers marks every reference in flight. It fails with
+// ErrDuplicateTransfer if any of them is known to have succeeded or is being
+// sent by another call, releasing the references it reserved. A reference an
+// earlier call left pending is taken over and verified with Chapa first.
+func (c *chapa) reserveTransfers(ctx context.Context, references []string) error {
+	reserved := make([]string, 0, len(references))
+
+	for _, reference := range references {
+		err := c.reserveTransfer(ctx, reference)
+		if err != nil {
+			c.settleTransfers(ctx, reserved, "")
+			return err
+		}
+
+		reserved = append(reserved, reference)
+	}
+
+	return nil
+}
+
+func (c *chapa) reserveTransfer(ctx context.Context, reference string) error {
+	state, loaded, err := c.idempotency.LoadOrStore(ctx, reference, IdempotencyInFlight)
+	if err != nil || !loaded {
+		return err
+	}
+
+	switch state {
+	case IdempotencySucceeded:
+		return fmt.Errorf("%w: %s", ErrDuplicateTransfer, reference)
+	case IdempotencyPending:
+		swapped, err := c.idempotency.CompareAndSwap(ctx, reference, IdempotencyPending, IdempotencyInFlight)
+		if err != nil {
+			return err
+		}
+		if swapped {
+			err = c.checkTransfersUnknown(ctx, []string{reference})
+			if errors.Is(err, ErrTransferAlreadySent) {
+				return fmt.Errorf("%w: %s", ErrDuplicateTransfer, reference)
+			}
+			if err != nil {
+				// leave the reference pending for the next call to verify
+				c.settleTransfers(ctx, []string{reference}, IdempotencyPending)
+			}
+			return err
+		}
+	}
+
+	return fmt.Errorf("%w: %s is being sent", ErrDuplicateTransfer, reference)
+}
+
+// checkTransfersUnknown verifies with Chapa that none of the references has
+// been transferred. A reference found at Chapa is recorded as succeeded and
+// reported with a *TransferSentError.
+func (c *chapa) checkTransfersUnknown(ctx context.Context, references []string) error {
+	for _, reference := range references {
+		response, err := c.VerifyTransfer(ctx, reference)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := c.idempotency.Store(ctx, reference, IdempotencySucceeded); err != nil {
+			return err
+		}
+		return &TransferSentError{Reference: reference, Transfer: response.Data}
+	}
+
+	return nil
+}
+
+// settleTransfers stores state for every reference, or deletes them when
+// state is empty. Failures are logged since the transfer outcome is final.
+func (c *chapa) settleTransfers(ctx context.Context, references []string, state IdempotencyState) {
+	for _, reference := range references {
+		var err error
+		if state == "" {
+			err = c.idempotency.Delete(ctx, reference)
+		} else {
+			err = c.idempotency.Store(ctx, reference, state)
+		}
+
+		if err != nil {
+			c.logger.Printf("error while updating idempotency store for reference %v: %v", reference, err)
+		}
+	}
+}
+
+// isAmbiguous reports whether a failed request may still have been processed
+// by Chapa.
+func isAmbiguous(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode >= http.StatusInternalServerError
+	}
+
+	var validationErr *ValidationError
+	return !errors.As(err, &validationErr)
+}
@@ -0,0 +1,238 @@
+package chapa
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIdempotencyGuard(t *testing.T) {
+	// transferServer answers transfer requests with the given status codes in
+	// turn, and transfer verification with verifyStatus.
+	transferServer := func(verifyStatus int, transferStatuses ...int) (*httptest.Server, *int32) {
+		var transfers int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if strings.HasPrefix(r.URL.Path, "/v1/transfers/verify/") {
+				w.WriteHeader(verifyStatus)
+				_, _ = w.Write([]byte(`{"message":"Transfer details","status":"success"}`))
+				return
+			}
+
+			n := atomic.AddInt32(&transfers, 1)
+			status := http.StatusOK
+			if int(n) <= len(transferStatuses) {
+				status = transferStatuses[n-1]
+			}
+			w.WriteHeader(status)
+			_, _ = w.Write([]byte(`{"message":"Transfer queued successfully","status":"success","data":"ok"}`))
+		}))
+		t.Cleanup(server.Close)
+
+		return server, &transfers
+	}
+
+	newRequest := func() *BankTransfer {
+		return &BankTransfer{
+			AccountName:   "Leul Abay Ejigu",
+			AccountNumber: "1000212482106",
+			Amount:        10,
+			Currency:      "ETB",
+			Reference:     RandomString(14),
+			BankCode:      "946",
+		}
+	}
+
+	t.Run("refuses to resend a successful reference", func(t *testing.T) {
+		server, transfers := transferServer(http.StatusNotFound)
+		paymentProvider := NewClient("key", WithBaseURL(server.URL))
+		request := newRequest()
+
+		_, err := paymentProvider.TransferToBank(request)
+		assert.NoError(t, err)
+
+		response, err := paymentProvider.TransferToBank(request)
+		assert.Nil(t, response)
+		assert.True(t, errors.Is(err, ErrDuplicateTransfer))
+		assert.Equal(t, int32(1), atomic.LoadInt32(transfers))
+	})
+
+	t.Run("verifies an ambiguous reference before resending", func(t *testing.T) {
+		server, transfers := transferServer(http.StatusOK, http.StatusBadGateway)
+		paymentProvider := NewClient("key", WithBaseURL(server.URL))
+		request := newRequest()
+
+		_, err := paymentProvider.TransferToBank(request)
+		assert.True(t, errors.Is(err, ErrServer))
+
+		_, err = paymentProvider.TransferToBank(request)
+		assert.True(t, errors.Is(err, ErrDuplicateTransfer))
+		assert.Equal(t, int32(1), atomic.LoadInt32(transfers))
+	})
+
+	t.Run("refuses a concurrent send of the same reference", func(t *testing.T) {
+		var transfers int32
+		received, release := make(chan struct{}), make(chan struct{})
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if strings.HasPrefix(r.URL.Path, "/v1/transfers/verify/") {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+
+			atomic.AddInt32(&transfers, 1)
+			close(received)
+			<-release
+			_, _ = w.Write([]byte(`{"message":"Transfer queued successfully","status":"success","data":"ok"}`))
+		}))
+		t.Cleanup(server.Close)
+
+		paymentProvider := NewClient("key", WithBaseURL(server.URL))
+		request := newRequest()
+
+		done := make(chan error)
+		go func() {
+			_, err := paymentProvider.TransferToBank(request)
+			done <- err
+		}()
+		<-received
+
+		_, err := paymentProvider.TransferToBank(request)
+		assert.True(t, errors.Is(err, ErrDuplicateTransfer))
+
+		close(release)
+		assert.NoError(t, <-done)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&transfers))
+	})
+
+	t.Run("keeps a timed-out reference pending when verifying it fails", func(t *testing.T) {
+		var transfers int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if strings.HasPrefix(r.URL.Path, "/v1/transfers/verify/") {
+				w.WriteHeader(http.StatusUnauthorized)
+				_, _ = w.Write([]byte(`{"message":"Invalid API Key","status":"failed"}`))
+				return
+			}
+
+			if atomic.AddInt32(&transfers, 1) == 1 {
+				// processed, but answered after the client gave up
+				time.Sleep(200 * time.Millisecond)
+			}
+			_, _ = w.Write([]byte(`{"message":"Transfer queued successfully","status":"success","data":"ok"}`))
+		}))
+		t.Cleanup(server.Close)
+
+		store := NewMemoryIdempotencyStore(DefaultIdempotencyTTL)
+		paymentProvider := NewClient("key",
+			WithBaseURL(server.URL),
+			WithTimeout(50*time.Millisecond),
+			WithIdempotencyStore(store),
+			WithRetryPolicy(RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}),
+		)
+		request := newRequest()
+
+		ctx := AllowTransferRetry(context.Background(), request.Reference)
+		_, err := paymentProvider.TransferToBankWithContext(ctx, request)
+		assert.True(t, errors.Is(err, ErrUnauthorized))
+
+		state, _, _ := store.LoadOrStore(context.Background(), request.Reference, IdempotencyInFlight)
+		assert.Equal(t, IdempotencyPending, state)
+
+		_, err = paymentProvider.TransferToBank(request)
+		assert.True(t, errors.Is(err, ErrUnauthorized))
+		assert.Equal(t, int32(1), atomic.LoadInt32(&transfers))
+	})
+
+	t.Run("resends an ambiguous reference unknown to chapa", func(t *testing.T) {
+		server, transfers := transferServer(http.StatusNotFound, http.StatusBadGateway)
+		paymentProvider := NewClient("key", WithBaseURL(server.URL))
+		request := newRequest()
+
+		_, err := paymentProvider.TransferToBank(request)
+		assert.Error(t, err)
+
+		response, err := paymentProvider.TransferToBank(request)
+		assert.NoError(t, err)
+		assert.Equal(t, "success", response.Status)
+		assert.Equal(t, int32(2), atomic.LoadInt32(transfers))
+	})
+
+	t.Run("releases a reference after a definitive failure", func(t *testing.T) {
+		server, transfers := transferServer(http.StatusNotFound, http.StatusBadRequest)
+		store := NewMemoryIdempotencyStore(DefaultIdempotencyTTL)
+		paymentProvider := NewClient("key", WithBaseURL(server.URL), WithIdempotencyStore(store))
+		request := newRequest()
+
+		_, err := paymentProvider.TransferToBank(request)
+		assert.True(t, errors.Is(err, ErrBadRequest))
+
+		_, loaded, _ := store.LoadOrStore(context.Background(), request.Reference, IdempotencyPending)
+		assert.False(t, loaded)
+		assert.NoError(t, store.Delete(context.Background(), request.Reference))
+
+		_, err = paymentProvider.TransferToBank(request)
+		assert.NoError(t, err)
+		assert.Equal(t, int32(2), atomic.LoadInt32(transfers))
+	})
+
+	t.Run("verifies between retries", func(t *testing.T) {
+		server, transfers := transferServer(http.StatusOK, http.StatusServiceUnavailable)
+		store := NewMemoryIdempotencyStore(DefaultIdempotencyTTL)
+		paymentProvider := NewClient("key",
+			WithBaseURL(server.URL),
+			WithIdempotencyStore(store),
+			WithRetryPolicy(RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}),
+		)
+		request := newRequest()
+
+		ctx := AllowTransferRetry(context.Background(), request.Reference)
+		_, err := paymentProvider.TransferToBankWithContext(ctx, request)
+		assert.False(t, errors.Is(err, ErrDuplicateTransfer))
+		var sentErr *TransferSentError
+		assert.True(t, errors.As(err, &sentErr))
+		assert.Equal(t, request.Reference, sentErr.Reference)
+		assert.Equal(t, int32(1), atomic.LoadInt32(transfers))
+
+		state, _, _ := store.LoadOrStore(context.Background(), request.Reference, IdempotencyPending)
+		assert.Equal(t, IdempotencySucceeded, state)
+	})
+}
+
+func TestMemoryIdempotencyStore(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	store := NewMemoryIdempotencyStore(time.Hour)
+	store.now = func() time.Time { return now }
+
+	assert.NoError(t, store.Store(ctx, "ref-1", IdempotencySucceeded))
+	assert.NoError(t, store.Store(ctx, "ref-2", IdempotencyPending))
+
+	t.Run("remembers succeeded references within the ttl", func(t *testing.T) {
+		state, loaded, _ := store.LoadOrStore(ctx, "ref-1", IdempotencyInFlight)
+		assert.True(t, loaded)
+		assert.Equal(t, IdempotencySucceeded, state)
+	})
+
+	t.Run("evicts succeeded references after the ttl", func(t *testing.T) {
+		now = now.Add(2 * time.Hour)
+		assert.NoError(t, store.Store(ctx, "ref-3", IdempotencyInFlight))
+		assert.Len(t, store.entries, 2)
+
+		_, loaded, _ := store.LoadOrStore(ctx, "ref-1", IdempotencyInFlight)
+		assert.False(t, loaded)
+	})
+
+	t.Run("keeps pending references", func(t *testing.T) {
+		swapped, err := store.CompareAndSwap(ctx, "ref-2", IdempotencyPending, IdempotencyInFlight)
+		assert.NoError(t, err)
+		assert.True(t, swapped)
+
+		swapped, _ = store.CompareAndSwap(ctx, "ref-2", IdempotencyPending, IdempotencyInFlight)
+		assert.False(t, swapped)
+	})
+}
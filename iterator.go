@@ -0,0 +1,136 @@
+package chapa
+
+import "context"
+
+// TransactionLister fetches pages of transactions. It is implemented by the
+// API client and by test doubles.
+type TransactionLister interface {
+	ListTransactions(ctx context.Context, filter *TransactionFilter) (*TransactionsResponse, error)
+}
+
+// IteratorOption configures a TransactionIterator.
+type IteratorOption func(*TransactionIterator)
+
+// WithPrefetch makes the iterator fetch the next page in the background while
+// the current one is being consumed.
+func WithPrefetch() IteratorOption {
+	return func(it *TransactionIterator) {
+		it.prefetch = true
+	}
+}
+
+// TransactionIterator walks every page of a transaction listing lazily:
+//
+//	it := client.Transactions(ctx, &chapa.TransactionFilter{PerPage: 50})
+//	for it.Next() {
+//		fmt.Println(it.Transaction().TransID)
+//	}
+//	if err := it.Err(); err != nil {
+//		return err
+//	}
+type TransactionIterator struct {
+	ctx      context.Context
+	lister   TransactionLister
+	filter   TransactionFilter
+	prefetch bool
+
+	page    []Transaction
+	index   int
+	last    bool
+	err     error
+	pending chan pageResult
+}
+
+type pageResult struct {
+	response *TransactionsResponse
+	err      error
+}
+
+// NewTransactionIterator returns an iterator over the transactions lister
+// returns for filter, starting at filter.Page or the first page.
+func NewTransactionIterator(ctx context.Context, lister TransactionLister, filter *TransactionFilter, opts ...IteratorOption) *TransactionIterator {
+	it := &TransactionIterator{ctx: ctx, lister: lister, index: -1}
+	if filter != nil {
+		it.filter = *filter
+	}
+	if it.filter.Page < 1 {
+		it.filter.Page = 1
+	}
+
+	for _, opt := range opts {
+		opt(it)
+	}
+
+	return it
+}
+
+// Next advances to the next transaction, fetching the next page when the
+// current one is exhausted. It returns false when there are no more
+// transactions or a page could not be fetched; Err tells the two apart.
+func (it *TransactionIterator) Next() bool {
+	if it.err != nil {
+		return false
+	}
+
+	it.index++
+	for it.index >= len(it.page) {
+		if it.last {
+			return false
+		}
+
+		response, err := it.fetch()
+		if err != nil {
+			it.err = err
+			return false
+		}
+
+		it.page = response.Data.Transactions
+		it.index = 0
+		it.last = response.Data.Pagination.NextPageURL == "" || len(it.page) == 0
+		it.filter.Page++
+
+		if it.prefetch && !it.last {
+			it.fetchAhead()
+		}
+	}
+
+	return true
+}
+
+// Transaction returns the current transaction. It is only valid after a call
+// to Next returned true.
+func (it *TransactionIterator) Transaction() Transaction {
+	if it.index < 0 || it.index >= len(it.page) {
+		return Transaction{}
+	}
+	return it.page[it.index]
+}
+
+// Err returns the error that stopped the iteration, if any.
+func (it *TransactionIterator) Err() error {
+	return it.err
+}
+
+// fetch returns the page at it.filter.Page, waiting for it if it is being
+// prefetched.
+func (it *TransactionIterator) fetch() (*TransactionsResponse, error) {
+	if it.pending != nil {
+		result := <-it.pending
+		it.pending = nil
+		return result.response, result.err
+	}
+
+	filter := it.filter
+	return it.lister.ListTransactions(it.ctx, &filter)
+}
+
+// fetchAhead starts fetching the page at it.filter.Page in the background.
+func (it *TransactionIterator) fetchAhead() {
+	filter := it.filter
+	pending := make(chan pageResult, 1)
+	go func() {
+		response, err := it.lister.ListTransactions(it.ctx, &filter)
+		pending <- pageResult{response: response, err: err}
+	}()
+	it.pending = pending
+}
@@ -0,0 +1,134 @@
+package chapa
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// pagedLister serves total transactions in pages of perPage and records the
+// pages asked for.
+type pagedLister struct {
+	total   int
+	perPage int
+	failAt  int
+
+	mu    sync.Mutex
+	pages []int
+}
+
+func (l *pagedLister) ListTransactions(_ context.Context, filter *TransactionFilter) (*TransactionsResponse, error) {
+	l.mu.Lock()
+	l.pages = append(l.pages, filter.Page)
+	l.mu.Unlock()
+
+	if filter.Page == l.failAt {
+		return nil, &APIError{StatusCode: http.StatusInternalServerError}
+	}
+
+	var response TransactionsResponse
+	for i := (filter.Page - 1) * l.perPage; i < filter.Page*l.perPage && i < l.total; i++ {
+		response.Data.Transactions = append(response.Data.Transactions, Transaction{TransID: fmt.Sprintf("tx-%d", i)})
+	}
+	if filter.Page*l.perPage < l.total {
+		response.Data.Pagination.NextPageURL = fmt.Sprintf("https://api.chapa.co/v1/transactions?page=%d", filter.Page+1)
+	}
+	return &response, nil
+}
+
+func TestTransactionIterator(t *testing.T) {
+	ctx := context.Background()
+
+	collect := func(it *TransactionIterator) []string {
+		var ids []string
+		for it.Next() {
+			ids = append(ids, it.Transaction().TransID)
+		}
+		return ids
+	}
+
+	t.Run("walks every page", func(t *testing.T) {
+		lister := &pagedLister{total: 7, perPage: 3}
+
+		ids := collect(NewTransactionIterator(ctx, lister, nil))
+		assert.Len(t, ids, 7)
+		assert.Equal(t, "tx-0", ids[0])
+		assert.Equal(t, "tx-6", ids[6])
+		assert.Equal(t, []int{1, 2, 3}, lister.pages)
+	})
+
+	t.Run("starts at the filter page", func(t *testing.T) {
+		lister := &pagedLister{total: 7, perPage: 3}
+
+		ids := collect(NewTransactionIterator(ctx, lister, &TransactionFilter{Page: 2}))
+		assert.Equal(t, []string{"tx-3", "tx-4", "tx-5", "tx-6"}, ids)
+	})
+
+	t.Run("handles an empty listing", func(t *testing.T) {
+		it := NewTransactionIterator(ctx, &pagedLister{perPage: 3}, nil)
+		assert.False(t, it.Next())
+		assert.NoError(t, it.Err())
+		assert.Equal(t, Transaction{}, it.Transaction())
+	})
+
+	t.Run("stops at the first error", func(t *testing.T) {
+		lister := &pagedLister{total: 7, perPage: 3, failAt: 2}
+
+		it := NewTransactionIterator(ctx, lister, nil)
+		assert.Len(t, collect(it), 3)
+		assert.ErrorIs(t, it.Err(), ErrServer)
+		assert.False(t, it.Next())
+	})
+
+	t.Run("prefetches the next page", func(t *testing.T) {
+		lister := &pagedLister{total: 7, perPage: 3}
+
+		it := NewTransactionIterator(ctx, lister, nil, WithPrefetch())
+		assert.True(t, it.Next())
+		assert.Eventually(t, func() bool {
+			lister.mu.Lock()
+			defer lister.mu.Unlock()
+			return len(lister.pages) == 2
+		}, time.Second, time.Millisecond)
+
+		ids := append([]string{it.Transaction().TransID}, collect(it)...)
+		assert.Len(t, ids, 7)
+		assert.Equal(t, []int{1, 2, 3}, lister.pages)
+	})
+
+	t.Run("follows the transactions endpoint", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			page := r.URL.Query().Get("page")
+			next := ""
+			if page == "1" {
+				next = "https://api.chapa.co/v1/transactions?page=2"
+			}
+			_, _ = fmt.Fprintf(w, `{"status":"success","data":{"transactions":[{"trans_id":"tx-%s"}],"pagination":{"next_page_url":%q}}}`, page, next)
+		}))
+		defer server.Close()
+
+		paymentProvider := NewClient("key", WithBaseURL(server.URL))
+		it := paymentProvider.Transactions(ctx, &TransactionFilter{PerPage: 1})
+		assert.Equal(t, []string{"tx-1", "tx-2"}, collect(it))
+		assert.NoError(t, it.Err())
+	})
+
+	t.Run("stops when the context is cancelled", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		defer server.Close()
+
+		cancelled, cancel := context.WithCancel(ctx)
+		cancel()
+
+		it := NewClient("key", WithBaseURL(server.URL)).Transactions(cancelled, nil)
+		assert.False(t, it.Next())
+		assert.True(t, errors.Is(it.Err(), context.Canceled))
+	})
+}
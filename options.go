@@ -0,0 +1,123 @@
+package chapa
+
+import (
+	"log"
+	"net/http"
+	"time"
+)
+
+const (
+	defaultBaseURL    = "https://api.chapa.co"
+	defaultAPIVersion = "v1"
+	defaultUserAgent  = "chapa-go"
+)
+
+// Logger is the logging interface used by the client. *log.Logger satisfies it.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// Option configures a client created with NewClient.
+type Option func(*chapa)
+
+// WithHTTPClient sets the HTTP client used to talk to Chapa.
+func WithHTTPClient(client *http.Client) Option {
+	return func(c *chapa) {
+		c.client = client
+	}
+}
+
+// WithBaseURL overrides the Chapa API host, e.g. to point at a stand-in server.
+// The base URL may carry a path prefix, such as an egress proxy route; the API
+// version and endpoint path are appended to it.
+func WithBaseURL(baseURL string) Option {
+	return func(c *chapa) {
+		c.baseURL = baseURL
+	}
+}
+
+// WithAPIVersion sets the API version prefix of every endpoint. Defaults to "v1".
+func WithAPIVersion(version string) Option {
+	return func(c *chapa) {
+		c.version = version
+	}
+}
+
+// WithTimeout sets the timeout of every request made by the client.
+// When combined with WithHTTPClient the given client is copied, not modified.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *chapa) {
+		c.timeout = timeout
+	}
+}
+
+// WithUserAgent sets the User-Agent header sent with every request.
+func WithUserAgent(userAgent string) Option {
+	return func(c *chapa) {
+		c.userAgent = userAgent
+	}
+}
+
+// WithLogger sets the logger used for warnings and errors.
+func WithLogger(logger Logger) Option {
+	return func(c *chapa) {
+		c.logger = logger
+	}
+}
+
+// WithRetryPolicy enables retries of failed requests. Reads such as Verify,
+// GetBanks and GetTransactions are retried under the policy; transfers only
+// when their references are marked with AllowTransferRetry.
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(c *chapa) {
+		c.retry = policy
+	}
+}
+
+// WithIdempotencyStore sets the store used to guard transfer references
+// against being sent twice. NewClient uses an in-memory store by default,
+// which remembers succeeded references for DefaultIdempotencyTTL; passing nil
+// disables the guard.
+func WithIdempotencyStore(store IdempotencyStore) Option {
+	return func(c *chapa) {
+		c.idempotency = store
+	}
+}
+
+// WithRefundLedger sets the ledger used to keep the refunds of a transaction
+// within its amount. NewClient uses an in-memory ledger by default, which
+// only covers refunds made by this process; passing nil disables the guard.
+func WithRefundLedger(ledger RefundLedger) Option {
+	return func(c *chapa) {
+		c.refunds = ledger
+	}
+}
+
+// NewClient creates a Chapa API client authenticated with apiKey.
+// Unlike New, it does not read any global configuration.
+func NewClient(apiKey string, opts ...Option) API {
+	c := &chapa{
+		apiKey:      apiKey,
+		baseURL:     defaultBaseURL,
+		version:     defaultAPIVersion,
+		userAgent:   defaultUserAgent,
+		logger:      log.Default(),
+		idempotency: NewMemoryIdempotencyStore(DefaultIdempotencyTTL),
+		refunds:     NewMemoryRefundLedger(),
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	switch {
+	case c.client == nil:
+		c.client = &http.Client{Timeout: c.timeout}
+	case c.timeout > 0:
+		client := *c.client
+		client.Timeout = c.timeout
+		c.client = &client
+	}
+
+	return c
+}
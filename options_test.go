@@ -0,0 +1,57 @@
+package chapa
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewClient(t *testing.T) {
+	t.Run("sends key and user agent to the configured base url", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/v1/banks", r.URL.Path)
+			assert.Equal(t, "Bearer CHASECK_TEST-key", r.Header.Get("Authorization"))
+			assert.Equal(t, "my-app/1.0", r.Header.Get("User-Agent"))
+			_, _ = w.Write([]byte(`{"message":"Banks retrieved","data":[{"id":946,"name":"Awash Bank"}]}`))
+		}))
+		defer server.Close()
+
+		paymentProvider := NewClient("CHASECK_TEST-key",
+			WithBaseURL(server.URL),
+			WithUserAgent("my-app/1.0"),
+		)
+
+		response, err := paymentProvider.GetBanks()
+		assert.NoError(t, err)
+		assert.Equal(t, "Banks retrieved", response.Message)
+		assert.Len(t, response.Data, 1)
+	})
+
+	t.Run("builds endpoints from base url prefix and api version", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/egress/chapa/v2/transaction/verify/ref%2F1", r.URL.EscapedPath())
+			_, _ = w.Write([]byte(`{"message":"Payment details","status":"success"}`))
+		}))
+		defer server.Close()
+
+		paymentProvider := NewClient("key",
+			WithBaseURL(server.URL+"/egress/chapa/"),
+			WithAPIVersion("v2"),
+		)
+
+		response, err := paymentProvider.Verify("ref/1")
+		assert.NoError(t, err)
+		assert.Equal(t, "success", response.Status)
+	})
+
+	t.Run("timeout does not modify the given http client", func(t *testing.T) {
+		httpClient := &http.Client{}
+
+		paymentProvider := NewClient("key", WithHTTPClient(httpClient), WithTimeout(time.Second)).(*chapa)
+		assert.Equal(t, time.Second, paymentProvider.client.Timeout)
+		assert.Zero(t, httpClient.Timeout)
+	})
+}
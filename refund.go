@@ -0,0 +1,148 @@
+package chapa
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/shopspring/decimal"
+)
+
+var (
+	// ErrRefundExceedsAmount is returned when a refund would take the
+	// refunds of a transaction past the amount that was paid.
+	ErrRefundExceedsAmount = errors.New("chapa: refunds exceed the transaction amount")
+	// ErrDuplicateRefund is returned when a refund reference was already used.
+	ErrDuplicateRefund = errors.New("chapa: refund reference already used")
+	// ErrNotRefundable is returned when a refund is asked for a transaction
+	// that has not been paid successfully.
+	ErrNotRefundable = errors.New("chapa: transaction is not refundable")
+)
+
+// RefundLedger records the refunds made on each transaction so that their
+// total never exceeds the amount paid. Implementations must be safe for
+// concurrent use.
+type RefundLedger interface {
+	// Reserve records amount as refunded on txRef under reference. It returns
+	// ErrRefundExceedsAmount if the refunds recorded for txRef plus amount
+	// would exceed limit, and ErrDuplicateRefund if reference is recorded.
+	Reserve(ctx context.Context, txRef, reference string, amount, limit decimal.Decimal) error
+	// Release forgets the refund recorded under reference, after Chapa
+	// refused it.
+	Release(ctx context.Context, txRef, reference string) error
+	// Refunded returns the total recorded for txRef.
+	Refunded(ctx context.Context, txRef string) (decimal.Decimal, error)
+}
+
+// MemoryRefundLedger is a RefundLedger kept in process memory.
+//
+// Chapa does not report how much of a transaction has been refunded, so the
+// ledger cannot be seeded from the API: it only knows the refunds made through
+// this process since it started. After a restart, or with several instances,
+// the guard is best-effort, and Chapa's own checks are the last line of
+// defence. Use a shared RefundLedger to keep the guarantee across processes.
+type MemoryRefundLedger struct {
+	mu      sync.Mutex
+	refunds map[string]map[string]decimal.Decimal
+}
+
+// NewMemoryRefundLedger creates an empty in-memory RefundLedger.
+func NewMemoryRefundLedger() *MemoryRefundLedger {
+	return &MemoryRefundLedger{
+		refunds: make(map[string]map[string]decimal.Decimal),
+	}
+}
+
+func (l *MemoryRefundLedger) Reserve(_ context.Context, txRef, reference string, amount, limit decimal.Decimal) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	refunds := l.refunds[txRef]
+	if _, ok := refunds[reference]; ok {
+		return ErrDuplicateRefund
+	}
+	if sumRefunds(refunds).Add(amount).GreaterThan(limit) {
+		return ErrRefundExceedsAmount
+	}
+
+	if refunds == nil {
+		refunds = make(map[string]decimal.Decimal)
+		l.refunds[txRef] = refunds
+	}
+	refunds[reference] = amount
+	return nil
+}
+
+func (l *MemoryRefundLedger) Release(_ context.Context, txRef, reference string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.refunds[txRef], reference)
+	return nil
+}
+
+func (l *MemoryRefundLedger) Refunded(_ context.Context, txRef string) (decimal.Decimal, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return sumRefunds(l.refunds[txRef]), nil
+}
+
+func sumRefunds(refunds map[string]decimal.Decimal) decimal.Decimal {
+	total := decimal.Zero
+	for _, amount := range refunds {
+		total = total.Add(amount)
+	}
+	return total
+}
+
+// sendRefund sends a refund of txRef through send, guarded by the refund
+// ledger. Only successful transactions are refunded, and the amount paid is
+// taken from Verify; a refund without an amount is sent for whatever has not
+// been refunded yet. A refund that fails ambiguously stays recorded, since Chapa
+// may have made it.
+func (c *chapa) sendRefund(ctx context.Context, txRef string, request *RefundRequest, send func() error) error {
+	if c.refunds == nil {
+		return send()
+	}
+
+	verified, err := c.VerifyWithContext(ctx, txRef)
+	if err != nil {
+		return err
+	}
+	if verified.Data.Status != SuccessTransactionStatus {
+		return fmt.Errorf("%w: %v is %v", ErrNotRefundable, txRef, verified.Data.Status)
+	}
+
+	amount := request.Amount
+	if amount.IsZero() {
+		refunded, err := c.refunds.Refunded(ctx, txRef)
+		if err != nil {
+			return err
+		}
+
+		amount = verified.Data.Amount.Sub(refunded)
+		if !amount.IsPositive() {
+			return fmt.Errorf("%w: %v is fully refunded", ErrRefundExceedsAmount, txRef)
+		}
+		// ask Chapa for exactly what the ledger records
+		request.Amount = amount
+	}
+	if err = c.refunds.Reserve(ctx, txRef, request.Reference, amount, verified.Data.Amount); err != nil {
+		return err
+	}
+
+	err = send()
+	switch {
+	case err == nil:
+	case isAmbiguous(err):
+		c.logger.Printf("warning refund outcome unknown for %v reference %v: %v", txRef, request.Reference, err)
+	default:
+		if releaseErr := c.refunds.Release(ctx, txRef, request.Reference); releaseErr != nil {
+			c.logger.Printf("error releasing refund %v of %v: %v", request.Reference, txRef, releaseErr)
+		}
+	}
+
+	return err
+}
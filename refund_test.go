@@ -0,0 +1,178 @@
+package chapa
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRefundGuard(t *testing.T) {
+	ctx := context.Background()
+
+	// refundServer reports a paid transaction of 100 ETB and answers refund
+	// requests with the given status codes in turn, keeping their bodies.
+	refundServer := func(refundStatuses ...int) (*httptest.Server, *[]map[string]interface{}) {
+		var mu sync.Mutex
+		var refunds []map[string]interface{}
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if strings.HasPrefix(r.URL.Path, "/v1/transaction/verify/") {
+				_, _ = w.Write([]byte(`{"message":"Payment details","status":"success","data":{"amount":"100.00","currency":"ETB","status":"success","tx_ref":"tx-1"}}`))
+				return
+			}
+
+			assert.Equal(t, "/v1/refund/tx-1", r.URL.Path)
+			var body map[string]interface{}
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+
+			mu.Lock()
+			refunds = append(refunds, body)
+			status := http.StatusOK
+			if len(refunds) <= len(refundStatuses) {
+				status = refundStatuses[len(refunds)-1]
+			}
+			mu.Unlock()
+
+			w.WriteHeader(status)
+			_, _ = w.Write([]byte(`{"message":"Refund processed successfully","status":"success","data":{"tx_ref":"tx-1","amount":"40.00","created_at":"2024-01-02T09:00:00Z"}}`))
+		}))
+		t.Cleanup(server.Close)
+
+		return server, &refunds
+	}
+
+	refund := func(amount int64) *RefundRequest {
+		return &RefundRequest{Amount: decimal.NewFromInt(amount), Reason: "customer request"}
+	}
+
+	t.Run("refunds part of a transaction", func(t *testing.T) {
+		server, refunds := refundServer()
+		paymentProvider := NewClient("key", WithBaseURL(server.URL))
+
+		response, err := paymentProvider.Refund(ctx, "tx-1", refund(40))
+		assert.NoError(t, err)
+		assert.Equal(t, "Refund processed successfully", response.Message)
+		assert.True(t, decimal.NewFromInt(40).Equal(response.Data.Amount))
+		assert.False(t, response.Data.CreatedAt.IsZero())
+
+		assert.Len(t, *refunds, 1)
+		assert.Equal(t, "40", (*refunds)[0]["amount"])
+		assert.Equal(t, "customer request", (*refunds)[0]["reason"])
+		assert.NotEmpty(t, (*refunds)[0]["reference"])
+	})
+
+	t.Run("refuses refunds past the amount paid", func(t *testing.T) {
+		server, refunds := refundServer()
+		paymentProvider := NewClient("key", WithBaseURL(server.URL))
+
+		_, err := paymentProvider.Refund(ctx, "tx-1", refund(60))
+		assert.NoError(t, err)
+		_, err = paymentProvider.Refund(ctx, "tx-1", refund(50))
+		assert.True(t, errors.Is(err, ErrRefundExceedsAmount))
+
+		_, err = paymentProvider.Refund(ctx, "tx-1", refund(40))
+		assert.NoError(t, err)
+		assert.Len(t, *refunds, 2)
+	})
+
+	t.Run("refunds the rest of a partly refunded transaction without an amount", func(t *testing.T) {
+		server, refunds := refundServer()
+		ledger := NewMemoryRefundLedger()
+		paymentProvider := NewClient("key", WithBaseURL(server.URL), WithRefundLedger(ledger))
+
+		_, err := paymentProvider.Refund(ctx, "tx-1", refund(60))
+		assert.NoError(t, err)
+		_, err = paymentProvider.Refund(ctx, "tx-1", &RefundRequest{})
+		assert.NoError(t, err)
+		assert.Len(t, *refunds, 2)
+		assert.Equal(t, "40", (*refunds)[1]["amount"])
+
+		refunded, _ := ledger.Refunded(ctx, "tx-1")
+		assert.True(t, decimal.NewFromInt(100).Equal(refunded))
+
+		_, err = paymentProvider.Refund(ctx, "tx-1", &RefundRequest{})
+		assert.True(t, errors.Is(err, ErrRefundExceedsAmount))
+		assert.Len(t, *refunds, 2)
+	})
+
+	t.Run("refunds a whole transaction without an amount", func(t *testing.T) {
+		server, refunds := refundServer()
+		paymentProvider := NewClient("key", WithBaseURL(server.URL))
+
+		_, err := paymentProvider.Refund(ctx, "tx-1", &RefundRequest{Reference: "refund-1"})
+		assert.NoError(t, err)
+		assert.Equal(t, "100", (*refunds)[0]["amount"])
+		assert.Equal(t, "refund-1", (*refunds)[0]["reference"])
+
+		_, err = paymentProvider.Refund(ctx, "tx-1", refund(1))
+		assert.True(t, errors.Is(err, ErrRefundExceedsAmount))
+	})
+
+	t.Run("refuses a reused reference", func(t *testing.T) {
+		server, _ := refundServer()
+		paymentProvider := NewClient("key", WithBaseURL(server.URL))
+
+		_, err := paymentProvider.Refund(ctx, "tx-1", &RefundRequest{Amount: decimal.NewFromInt(10), Reference: "refund-1"})
+		assert.NoError(t, err)
+		_, err = paymentProvider.Refund(ctx, "tx-1", &RefundRequest{Amount: decimal.NewFromInt(10), Reference: "refund-1"})
+		assert.True(t, errors.Is(err, ErrDuplicateRefund))
+	})
+
+	t.Run("releases refused refunds and keeps ambiguous ones", func(t *testing.T) {
+		server, _ := refundServer(http.StatusBadRequest, http.StatusBadGateway)
+		ledger := NewMemoryRefundLedger()
+		paymentProvider := NewClient("key", WithBaseURL(server.URL), WithRefundLedger(ledger))
+
+		_, err := paymentProvider.Refund(ctx, "tx-1", refund(30))
+		assert.True(t, errors.Is(err, ErrBadRequest))
+		refunded, _ := ledger.Refunded(ctx, "tx-1")
+		assert.True(t, refunded.IsZero())
+
+		_, err = paymentProvider.Refund(ctx, "tx-1", refund(30))
+		assert.True(t, errors.Is(err, ErrServer))
+		refunded, _ = ledger.Refunded(ctx, "tx-1")
+		assert.True(t, decimal.NewFromInt(30).Equal(refunded))
+	})
+
+	t.Run("refuses refunds of unpaid transactions", func(t *testing.T) {
+		refunds := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if strings.HasPrefix(r.URL.Path, "/v1/transaction/verify/") {
+				_, _ = w.Write([]byte(`{"message":"Payment details","status":"success","data":{"amount":"100.00","currency":"ETB","status":"pending","tx_ref":"tx-1"}}`))
+				return
+			}
+			refunds++
+		}))
+		t.Cleanup(server.Close)
+
+		response, err := NewClient("key", WithBaseURL(server.URL)).Refund(ctx, "tx-1", refund(10))
+		assert.Nil(t, response)
+		assert.True(t, errors.Is(err, ErrNotRefundable))
+		assert.Zero(t, refunds)
+	})
+
+	t.Run("sends unguarded refunds without a ledger", func(t *testing.T) {
+		server, refunds := refundServer()
+		paymentProvider := NewClient("key", WithBaseURL(server.URL), WithRefundLedger(nil))
+
+		_, err := paymentProvider.Refund(ctx, "tx-1", refund(500))
+		assert.NoError(t, err)
+		assert.Len(t, *refunds, 1)
+	})
+
+	t.Run("rejects negative amounts", func(t *testing.T) {
+		response, err := NewClient("key").Refund(ctx, "tx-1", refund(-5))
+		assert.Nil(t, response)
+
+		var validationErr *ValidationError
+		assert.True(t, errors.As(err, &validationErr))
+		assert.Equal(t, "amount must not be negative", validationErr.Fields["amount"])
+	})
+}
@@ -0,0 +1,159 @@
+package chapa
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy controls how requests that fail with a network error, a 429 or
+// a 5xx response are retried. The zero value disables retries.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first one.
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry. It doubles on every
+	// following retry.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between attempts. A Retry-After header asking
+	// for a longer delay stops retrying.
+	MaxBackoff time.Duration
+	// Jitter is the fraction, between 0 and 1, of every delay that is randomised.
+	Jitter float64
+}
+
+// DefaultRetryPolicy is a reasonable policy for use with WithRetryPolicy.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    3,
+	InitialBackoff: 500 * time.Millisecond,
+	MaxBackoff:     10 * time.Second,
+	Jitter:         0.5,
+}
+
+type transferRetryKey struct{}
+
+// AllowTransferRetry returns a context under which TransferToBank and
+// BulkTransfer may retry requests for the given references. Transfers are
+// never retried otherwise, since a retried request could move money twice.
+// A bulk transfer is retried only when every item reference is allowed.
+func AllowTransferRetry(ctx context.Context, references ...string) context.Context {
+	allowed := make(map[string]bool)
+	if parent, ok := ctx.Value(transferRetryKey{}).(map[string]bool); ok {
+		for reference := range parent {
+			allowed[reference] = true
+		}
+	}
+	for _, reference := range references {
+		allowed[reference] = true
+	}
+
+	return context.WithValue(ctx, transferRetryKey{}, allowed)
+}
+
+func transferRetryAllowed(ctx context.Context, references ...string) bool {
+	allowed, _ := ctx.Value(transferRetryKey{}).(map[string]bool)
+	if len(allowed) == 0 || len(references) == 0 {
+		return false
+	}
+
+	for _, reference := range references {
+		if !allowed[reference] {
+			return false
+		}
+	}
+	return true
+}
+
+// withRetry calls fn until it succeeds, fails with an error that is not
+// retryable, or the retry policy is exhausted. fn is called once when
+// retryable is false.
+func (c *chapa) withRetry(ctx context.Context, retryable bool, fn func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || !retryable || attempt >= c.retry.MaxAttempts || ctx.Err() != nil || !isRetryable(err) {
+			return err
+		}
+
+		delay, ok := c.retry.backoff(attempt, err)
+		if !ok {
+			return err
+		}
+
+		c.logger.Printf("retrying request after %v (attempt %d of %d): %v", delay, attempt+1, c.retry.MaxAttempts, err)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// backoff returns the delay before the retry following attempt. It reports
+// false when the server asked for a longer delay than the policy allows.
+func (p RetryPolicy) backoff(attempt int, err error) (time.Duration, bool) {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		if delay, ok := retryAfter(apiErr.Header); ok {
+			return delay, p.MaxBackoff <= 0 || delay <= p.MaxBackoff
+		}
+	}
+
+	delay := time.Duration(float64(p.InitialBackoff) * math.Pow(2, float64(attempt-1)))
+	if p.MaxBackoff > 0 && delay > p.MaxBackoff {
+		delay = p.MaxBackoff
+	}
+	if p.Jitter > 0 {
+		delay -= time.Duration(p.Jitter * rand.Float64() * float64(delay))
+	}
+
+	return delay, true
+}
+
+// retryAfter parses a Retry-After header given in seconds or as an HTTP date.
+func retryAfter(header http.Header) (time.Duration, bool) {
+	value := header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		delay := time.Until(date)
+		if delay < 0 {
+			delay = 0
+		}
+		return delay, true
+	}
+
+	return 0, false
+}
+
+// isRetryable reports whether err is a transport failure or a response that
+// is worth retrying.
+func isRetryable(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.StatusCode {
+		case http.StatusTooManyRequests,
+			http.StatusInternalServerError,
+			http.StatusBadGateway,
+			http.StatusServiceUnavailable,
+			http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
+}
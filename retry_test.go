@@ -0,0 +1,119 @@
+package chapa
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryPolicy(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: 10 * time.Millisecond, Jitter: 0.5}
+
+	// failing returns a client whose server fails the first failures requests
+	// with status, and a counter of the requests it received.
+	failing := func(failures int32, status int, header http.Header) (API, *int32) {
+		var calls int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if strings.HasPrefix(r.URL.Path, "/v1/transfers/verify/") {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			if atomic.AddInt32(&calls, 1) <= failures {
+				for key, values := range header {
+					w.Header()[key] = values
+				}
+				w.WriteHeader(status)
+				return
+			}
+			_, _ = w.Write([]byte(`{"message":"ok","status":"success"}`))
+		}))
+		t.Cleanup(server.Close)
+
+		return NewClient("key", WithBaseURL(server.URL), WithRetryPolicy(policy)), &calls
+	}
+
+	bankTransfer := &BankTransfer{
+		AccountName:   "Leul Abay Ejigu",
+		AccountNumber: "1000212482106",
+		Amount:        10,
+		Currency:      "ETB",
+		Reference:     "3241342142sfdd",
+		BankCode:      "946",
+	}
+
+	t.Run("retries idempotent calls on server errors", func(t *testing.T) {
+		paymentProvider, calls := failing(2, http.StatusServiceUnavailable, nil)
+
+		response, err := paymentProvider.Verify("ref")
+		assert.NoError(t, err)
+		assert.Equal(t, "success", response.Status)
+		assert.Equal(t, int32(3), atomic.LoadInt32(calls))
+	})
+
+	t.Run("gives up after max attempts", func(t *testing.T) {
+		paymentProvider, calls := failing(5, http.StatusTooManyRequests, nil)
+
+		_, err := paymentProvider.GetBanks()
+		assert.True(t, errors.Is(err, ErrRateLimited))
+		assert.Equal(t, int32(3), atomic.LoadInt32(calls))
+	})
+
+	t.Run("does not retry client errors", func(t *testing.T) {
+		paymentProvider, calls := failing(1, http.StatusBadRequest, nil)
+
+		_, err := paymentProvider.GetTransactions()
+		assert.True(t, errors.Is(err, ErrBadRequest))
+		assert.Equal(t, int32(1), atomic.LoadInt32(calls))
+	})
+
+	t.Run("stops when retry-after exceeds max backoff", func(t *testing.T) {
+		paymentProvider, calls := failing(1, http.StatusTooManyRequests, http.Header{"Retry-After": {"60"}})
+
+		_, err := paymentProvider.GetBanks()
+		assert.True(t, errors.Is(err, ErrRateLimited))
+		assert.Equal(t, int32(1), atomic.LoadInt32(calls))
+	})
+
+	t.Run("does not retry transfers without opt in", func(t *testing.T) {
+		paymentProvider, calls := failing(1, http.StatusBadGateway, nil)
+
+		_, err := paymentProvider.TransferToBank(bankTransfer)
+		assert.True(t, errors.Is(err, ErrServer))
+		assert.Equal(t, int32(1), atomic.LoadInt32(calls))
+	})
+
+	t.Run("retries transfers allowed for their reference", func(t *testing.T) {
+		paymentProvider, calls := failing(1, http.StatusBadGateway, nil)
+
+		ctx := AllowTransferRetry(context.Background(), bankTransfer.Reference)
+		response, err := paymentProvider.TransferToBankWithContext(ctx, bankTransfer)
+		assert.NoError(t, err)
+		assert.Equal(t, "success", response.Status)
+		assert.Equal(t, int32(2), atomic.LoadInt32(calls))
+	})
+
+	t.Run("bulk transfer needs every reference allowed", func(t *testing.T) {
+		paymentProvider, calls := failing(1, http.StatusBadGateway, nil)
+
+		request := &BulkTransferRequest{
+			Title:    "payroll",
+			Currency: "ETB",
+			BulkData: []BulkData{
+				{AccountName: "Leul Abay Ejigu", AccountNumber: "1000212482106", Amount: 10, Reference: "ref-1", BankCode: "946"},
+				{AccountName: "Leul Abay Ejigu", AccountNumber: "1000212482106", Amount: 10, Reference: "ref-2", BankCode: "946"},
+			},
+		}
+
+		ctx := AllowTransferRetry(context.Background(), "ref-1")
+		_, err := paymentProvider.BulkTransferWithContext(ctx, request)
+		assert.Error(t, err)
+		assert.Equal(t, int32(1), atomic.LoadInt32(calls))
+	})
+}
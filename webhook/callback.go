@@ -0,0 +1,230 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	chapa "github.com/Chapa-Et/chapa-go"
+)
+
+var (
+	// ErrPaymentNotCompleted is passed to failure callbacks when Chapa does not
+	// report the transaction as successful.
+	ErrPaymentNotCompleted = errors.New("webhook: payment not completed")
+	// ErrPaymentMismatch is passed to failure callbacks when the verified
+	// transaction does not match the original payment request.
+	ErrPaymentMismatch = errors.New("webhook: payment does not match request")
+)
+
+type (
+	// PaymentLookup returns the payment request originally sent for txRef, or
+	// nil if the reference is unknown.
+	PaymentLookup func(ctx context.Context, txRef string) (*chapa.PaymentRequest, error)
+
+	// PaymentHandlerFunc handles a payment verified with Chapa.
+	PaymentHandlerFunc func(ctx context.Context, request *chapa.PaymentRequest, transaction *chapa.VerifyData) error
+
+	// PaymentFailureFunc handles a payment that could not be confirmed.
+	// transaction is nil when Chapa does not know the reference.
+	PaymentFailureFunc func(ctx context.Context, request *chapa.PaymentRequest, transaction *chapa.VerifyData, reason error)
+)
+
+// CallbackHandler is an http.Handler for the CallbackURL and return URL of a
+// payment. Those hits are not authenticated, so their status parameter is
+// ignored: the handler verifies the reference with Chapa and compares amount
+// and currency with the original request before calling the success callback.
+//
+// It answers 400 without a reference, 404 for unknown references, 502 when
+// Chapa cannot be reached, 402 for payments that are not confirmed and 500
+// when the success callback fails. With redirects configured, confirmed and
+// unconfirmed payments are redirected instead.
+type CallbackHandler struct {
+	api        chapa.API
+	lookup     PaymentLookup
+	onSuccess  PaymentHandlerFunc
+	onFailure  PaymentFailureFunc
+	guard      *ReplayGuard
+	successURL string
+	failureURL string
+	logger     chapa.Logger
+}
+
+// CallbackOption configures a CallbackHandler created with NewCallbackHandler.
+type CallbackOption func(*CallbackHandler)
+
+// WithRedirects makes the handler redirect the customer to successURL or
+// failureURL, for use as the return URL of a payment.
+func WithRedirects(successURL, failureURL string) CallbackOption {
+	return func(h *CallbackHandler) {
+		h.successURL = successURL
+		h.failureURL = failureURL
+	}
+}
+
+// WithCallbackReplayGuard makes sure the success callback runs once per
+// reference, however many times the callback URL is hit within the guard's
+// maximum age. Guard entries expire after that, so a later hit runs the
+// callback again; onSuccess must stay idempotent.
+func WithCallbackReplayGuard(guard *ReplayGuard) CallbackOption {
+	return func(h *CallbackHandler) {
+		h.guard = guard
+	}
+}
+
+// WithCallbackLogger sets the logger used to report failed verifications.
+func WithCallbackLogger(logger chapa.Logger) CallbackOption {
+	return func(h *CallbackHandler) {
+		h.logger = logger
+	}
+}
+
+// NewCallbackHandler creates a CallbackHandler that verifies payments with api
+// and calls onSuccess for the ones that match the request returned by lookup.
+func NewCallbackHandler(api chapa.API, lookup PaymentLookup, onSuccess PaymentHandlerFunc, opts ...CallbackOption) *CallbackHandler {
+	h := &CallbackHandler{
+		api:       api,
+		lookup:    lookup,
+		onSuccess: onSuccess,
+		logger:    log.Default(),
+	}
+
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	return h
+}
+
+// OnFailure registers fn for payments that could not be confirmed.
+func (h *CallbackHandler) OnFailure(fn PaymentFailureFunc) {
+	h.onFailure = fn
+}
+
+func (h *CallbackHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	txRef := callbackReference(r)
+	if txRef == "" {
+		http.Error(w, "missing transaction reference", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	request, err := h.lookup(ctx, txRef)
+	if err != nil {
+		h.logger.Printf("error while looking up payment %v: %v", txRef, err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	if request == nil {
+		http.Error(w, "unknown transaction reference", http.StatusNotFound)
+		return
+	}
+
+	response, err := h.api.VerifyWithContext(ctx, txRef)
+	switch {
+	case errors.Is(err, chapa.ErrNotFound):
+		h.fail(w, r, request, nil, ErrPaymentNotCompleted)
+		return
+	case err != nil:
+		h.logger.Printf("error while verifying payment %v: %v", txRef, err)
+		http.Error(w, http.StatusText(http.StatusBadGateway), http.StatusBadGateway)
+		return
+	}
+
+	transaction := &response.Data
+	if err = matchPayment(request, transaction); err != nil {
+		h.fail(w, r, request, transaction, err)
+		return
+	}
+
+	if err = h.succeed(ctx, request, transaction); err != nil {
+		h.logger.Printf("error while handling payment %v: %v", txRef, err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	if h.successURL != "" {
+		http.Redirect(w, r, h.successURL, http.StatusSeeOther)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// succeed runs the success callback, once per reference within the guard's
+// maximum age if a guard is set.
+func (h *CallbackHandler) succeed(ctx context.Context, request *chapa.PaymentRequest, transaction *chapa.VerifyData) error {
+	if h.guard == nil {
+		return h.onSuccess(ctx, request, transaction)
+	}
+
+	// the hit itself carries no timestamp and a late return to the shop is
+	// legitimate, so only duplicates are filtered
+	id := "callback:" + request.TransactionRef
+	err := h.guard.Check(ctx, id, time.Time{})
+	if errors.Is(err, ErrDuplicateEvent) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if err = h.onSuccess(ctx, request, transaction); err != nil {
+		if releaseErr := h.guard.Release(ctx, id); releaseErr != nil {
+			h.logger.Printf("error while releasing payment %v: %v", request.TransactionRef, releaseErr)
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (h *CallbackHandler) fail(w http.ResponseWriter, r *http.Request, request *chapa.PaymentRequest, transaction *chapa.VerifyData, reason error) {
+	h.logger.Printf("warning payment %v not confirmed: %v", request.TransactionRef, reason)
+	if h.onFailure != nil {
+		h.onFailure(r.Context(), request, transaction, reason)
+	}
+
+	if h.failureURL != "" {
+		http.Redirect(w, r, h.failureURL, http.StatusSeeOther)
+		return
+	}
+	http.Error(w, reason.Error(), http.StatusPaymentRequired)
+}
+
+// callbackReference returns the transaction reference of a callback hit.
+// Chapa sends it as trx_ref on the callback URL and tx_ref on the return URL.
+func callbackReference(r *http.Request) string {
+	for _, key := range []string{"trx_ref", "tx_ref"} {
+		if value := strings.TrimSpace(r.FormValue(key)); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// matchPayment checks that a verified transaction settles request.
+func matchPayment(request *chapa.PaymentRequest, transaction *chapa.VerifyData) error {
+	if transaction.Status != chapa.SuccessTransactionStatus {
+		return fmt.Errorf("%w: status %q", ErrPaymentNotCompleted, transaction.Status)
+	}
+	if transaction.TxRef != "" && transaction.TxRef != request.TransactionRef {
+		return fmt.Errorf("%w: reference %q", ErrPaymentMismatch, transaction.TxRef)
+	}
+	if !transaction.Amount.Equal(request.Amount) {
+		return fmt.Errorf("%w: amount %v, expected %v", ErrPaymentMismatch, transaction.Amount, request.Amount)
+	}
+	if !strings.EqualFold(transaction.Currency, request.Currency) {
+		return fmt.Errorf("%w: currency %q, expected %q", ErrPaymentMismatch, transaction.Currency, request.Currency)
+	}
+	return nil
+}
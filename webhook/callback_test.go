@@ -0,0 +1,121 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	chapa "github.com/Chapa-Et/chapa-go"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCallbackHandler(t *testing.T) {
+	// verified maps a tx_ref to the verify payload the stand-in Chapa returns.
+	verified := map[string]string{
+		"paid":      `{"status":"success","data":{"status":"success","tx_ref":"paid","amount":"100.00","currency":"ETB"}}`,
+		"pending":   `{"status":"success","data":{"status":"pending","tx_ref":"pending","amount":100,"currency":"ETB"}}`,
+		"underpaid": `{"status":"success","data":{"status":"success","tx_ref":"underpaid","amount":1,"currency":"ETB"}}`,
+		"usd":       `{"status":"success","data":{"status":"success","tx_ref":"usd","amount":100,"currency":"USD"}}`,
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := verified[r.URL.Path[len("/v1/transaction/verify/"):]]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"message":"Invalid transaction reference","status":"failed"}`))
+			return
+		}
+		_, _ = w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	api := chapa.NewClient("key", chapa.WithBaseURL(server.URL))
+	lookup := func(_ context.Context, txRef string) (*chapa.PaymentRequest, error) {
+		if txRef == "unknown" {
+			return nil, nil
+		}
+		return &chapa.PaymentRequest{TransactionRef: txRef, Amount: decimal.NewFromInt(100), Currency: "ETB"}, nil
+	}
+	discard := WithCallbackLogger(log.New(io.Discard, "", 0))
+
+	hit := func(handler http.Handler, target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		return rec
+	}
+
+	t.Run("confirms a verified payment", func(t *testing.T) {
+		var confirmed *chapa.VerifyData
+		handler := NewCallbackHandler(api, lookup, func(_ context.Context, _ *chapa.PaymentRequest, transaction *chapa.VerifyData) error {
+			confirmed = transaction
+			return nil
+		}, discard)
+
+		rec := hit(handler, "/callback?trx_ref=paid&status=success")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "paid", confirmed.TxRef)
+	})
+
+	t.Run("does not trust the status parameter", func(t *testing.T) {
+		var reasons []error
+		handler := NewCallbackHandler(api, lookup, func(context.Context, *chapa.PaymentRequest, *chapa.VerifyData) error {
+			t.Fatal("unexpected success callback")
+			return nil
+		}, discard)
+		handler.OnFailure(func(_ context.Context, _ *chapa.PaymentRequest, _ *chapa.VerifyData, reason error) {
+			reasons = append(reasons, reason)
+		})
+
+		for _, ref := range []string{"pending", "underpaid", "usd", "forged"} {
+			rec := hit(handler, "/callback?trx_ref="+ref+"&status=success")
+			assert.Equal(t, http.StatusPaymentRequired, rec.Code, ref)
+		}
+
+		assert.Len(t, reasons, 4)
+		assert.True(t, errors.Is(reasons[0], ErrPaymentNotCompleted))
+		assert.True(t, errors.Is(reasons[1], ErrPaymentMismatch))
+		assert.True(t, errors.Is(reasons[2], ErrPaymentMismatch))
+		assert.True(t, errors.Is(reasons[3], ErrPaymentNotCompleted))
+	})
+
+	t.Run("redirects the customer", func(t *testing.T) {
+		handler := NewCallbackHandler(api, lookup, func(context.Context, *chapa.PaymentRequest, *chapa.VerifyData) error {
+			return nil
+		}, discard, WithRedirects("/orders/thanks", "/orders/retry"))
+
+		rec := hit(handler, "/return?tx_ref=paid")
+		assert.Equal(t, http.StatusSeeOther, rec.Code)
+		assert.Equal(t, "/orders/thanks", rec.Header().Get("Location"))
+
+		rec = hit(handler, "/return?tx_ref=pending")
+		assert.Equal(t, http.StatusSeeOther, rec.Code)
+		assert.Equal(t, "/orders/retry", rec.Header().Get("Location"))
+	})
+
+	t.Run("runs the success callback once with a replay guard", func(t *testing.T) {
+		calls := 0
+		handler := NewCallbackHandler(api, lookup, func(context.Context, *chapa.PaymentRequest, *chapa.VerifyData) error {
+			calls++
+			return nil
+		}, discard, WithCallbackReplayGuard(NewReplayGuard(NewMemoryStore(), time.Hour)))
+
+		assert.Equal(t, http.StatusOK, hit(handler, "/callback?trx_ref=paid").Code)
+		assert.Equal(t, http.StatusOK, hit(handler, "/callback?trx_ref=paid").Code)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("rejects missing and unknown references", func(t *testing.T) {
+		handler := NewCallbackHandler(api, lookup, func(context.Context, *chapa.PaymentRequest, *chapa.VerifyData) error {
+			return nil
+		}, discard)
+
+		assert.Equal(t, http.StatusBadRequest, hit(handler, "/callback").Code)
+		assert.Equal(t, http.StatusNotFound, hit(handler, "/callback?trx_ref=unknown").Code)
+	})
+}
@@ -0,0 +1,190 @@
+package webhook
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	chapa "github.com/Chapa-Et/chapa-go"
+	"github.com/shopspring/decimal"
+)
+
+// EventType is the value of the "event" field of a delivery.
+type EventType string
+
+const (
+	EventChargeSuccess   EventType = "charge.success"
+	EventChargeFailed    EventType = "charge.failed"
+	EventChargeCancelled EventType = "charge.cancelled"
+	EventChargeRefunded  EventType = "charge.refunded"
+	EventChargeReversed  EventType = "charge.reversed"
+	EventPayoutSuccess   EventType = "payout.success"
+	EventPayoutFailed    EventType = "payout.failed"
+	EventPayoutReversed  EventType = "payout.reversed"
+)
+
+// ErrMalformedEvent is returned when a delivery cannot be decoded.
+var ErrMalformedEvent = errors.New("webhook: malformed event")
+
+// Event is a decoded webhook delivery. Exactly one of Charge and Payout is set
+// for charge.* and payout.* events; other events only carry Raw.
+type Event struct {
+	Type   EventType
+	Charge *ChargeEvent
+	Payout *PayoutEvent
+	// Raw is the undecoded delivery body.
+	Raw json.RawMessage
+}
+
+// ChargeEvent reports a change in a customer payment.
+type ChargeEvent struct {
+	Type EventType
+	// Transaction is the payment. Its TransID is the merchant's tx_ref and its
+	// RefID is Chapa's reference.
+	Transaction   chapa.Transaction
+	Mode          string
+	Customization chapa.Customization
+	Meta          map[string]interface{}
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+// PayoutEvent reports a change in a transfer to a bank account or wallet.
+type PayoutEvent struct {
+	Type     EventType
+	Transfer chapa.Transfer
+}
+
+// ParseEvent decodes a delivery body. The signature must be verified first.
+func ParseEvent(body []byte) (*Event, error) {
+	var envelope struct {
+		Event EventType `json:"event"`
+	}
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformedEvent, err)
+	}
+	if envelope.Event == "" {
+		return nil, fmt.Errorf("%w: missing event type", ErrMalformedEvent)
+	}
+
+	event := &Event{
+		Type: envelope.Event,
+		Raw:  body,
+	}
+
+	switch {
+	case strings.HasPrefix(string(event.Type), "charge."):
+		event.Charge = &ChargeEvent{}
+		if err := json.Unmarshal(body, event.Charge); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrMalformedEvent, err)
+		}
+	case strings.HasPrefix(string(event.Type), "payout."):
+		event.Payout = &PayoutEvent{}
+		if err := json.Unmarshal(body, event.Payout); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrMalformedEvent, err)
+		}
+	}
+
+	return event, nil
+}
+
+func (e *ChargeEvent) UnmarshalJSON(data []byte) error {
+	var payload struct {
+		Event         EventType               `json:"event"`
+		Type          string                  `json:"type"`
+		FirstName     string                  `json:"first_name"`
+		LastName      string                  `json:"last_name"`
+		Email         string                  `json:"email"`
+		Mobile        string                  `json:"mobile"`
+		Currency      string                  `json:"currency"`
+		Amount        decimal.Decimal         `json:"amount"`
+		Charge        decimal.NullDecimal     `json:"charge"`
+		Status        chapa.TransactionStatus `json:"status"`
+		Mode          string                  `json:"mode"`
+		Reference     string                  `json:"reference"`
+		TxRef         string                  `json:"tx_ref"`
+		PaymentMethod string                  `json:"payment_method"`
+		Customization chapa.Customization     `json:"customization"`
+		Meta          map[string]interface{}  `json:"meta"`
+		CreatedAt     string                  `json:"created_at"`
+		UpdatedAt     string                  `json:"updated_at"`
+	}
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return err
+	}
+
+	// like the chapa payloads, an unknown timestamp format yields the zero
+	// time rather than losing the event; Transaction.CreatedAt keeps the raw value
+	createdAt, _ := chapa.ParseTime(payload.CreatedAt)
+	updatedAt, _ := chapa.ParseTime(payload.UpdatedAt)
+
+	var charge string
+	if payload.Charge.Valid {
+		charge = payload.Charge.Decimal.String()
+	}
+
+	*e = ChargeEvent{
+		Type: payload.Event,
+		Transaction: chapa.Transaction{
+			Status:        payload.Status,
+			RefID:         payload.Reference,
+			Type:          payload.Type,
+			CreatedAt:     payload.CreatedAt,
+			Currency:      payload.Currency,
+			Amount:        payload.Amount,
+			Charge:        charge,
+			TransID:       payload.TxRef,
+			PaymentMethod: payload.PaymentMethod,
+			Customer: chapa.Customer{
+				Email:     payload.Email,
+				FirstName: payload.FirstName,
+				LastName:  payload.LastName,
+				Mobile:    payload.Mobile,
+			},
+		},
+		Mode:          payload.Mode,
+		Customization: payload.Customization,
+		Meta:          payload.Meta,
+		CreatedAt:     createdAt,
+		UpdatedAt:     updatedAt,
+	}
+
+	return nil
+}
+
+func (e *PayoutEvent) UnmarshalJSON(data []byte) error {
+	var payload struct {
+		Event          EventType       `json:"event"`
+		BankID         json.RawMessage `json:"bank_id"`
+		ChapaReference string          `json:"chapa_reference"`
+		BankReference  string          `json:"bank_reference"`
+	}
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return err
+	}
+
+	var transfer chapa.Transfer
+	if err := json.Unmarshal(data, &transfer); err != nil {
+		return err
+	}
+
+	// payout deliveries name some transfer fields differently
+	if transfer.BankCode == "" && len(payload.BankID) > 0 && string(payload.BankID) != "null" {
+		transfer.BankCode = strings.Trim(string(payload.BankID), `"`)
+	}
+	if transfer.ChapaReference == "" {
+		transfer.ChapaReference = payload.ChapaReference
+	}
+	if transfer.CrossPartyReference == "" {
+		transfer.CrossPartyReference = payload.BankReference
+	}
+
+	*e = PayoutEvent{
+		Type:     payload.Event,
+		Transfer: transfer,
+	}
+
+	return nil
+}
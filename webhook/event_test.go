@@ -0,0 +1,125 @@
+package webhook
+
+import (
+	"testing"
+	"time"
+
+	chapa "github.com/Chapa-Et/chapa-go"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseEvent(t *testing.T) {
+	t.Run("charge event", func(t *testing.T) {
+		event, err := ParseEvent([]byte(`{
+			"event": "charge.success",
+			"first_name": "Bilen",
+			"last_name": "Gizachew",
+			"email": "abebech_bekele@gmail.com",
+			"mobile": null,
+			"currency": "ETB",
+			"amount": "100.00",
+			"charge": "3.50",
+			"status": "success",
+			"mode": "live",
+			"reference": "AP634JFwE8ZI",
+			"created_at": "2023-09-26T12:11:10.000000Z",
+			"updated_at": "2023-09-26T12:11:25.000000Z",
+			"type": "API",
+			"tx_ref": "chewatatest-6669",
+			"payment_method": "telebirr",
+			"customization": {"title": "Payment", "description": null, "logo": null},
+			"meta": null
+		}`))
+		assert.NoError(t, err)
+
+		assert.Equal(t, EventChargeSuccess, event.Type)
+		assert.Nil(t, event.Payout)
+
+		charge := event.Charge
+		assert.Equal(t, EventChargeSuccess, charge.Type)
+		assert.Equal(t, chapa.SuccessTransactionStatus, charge.Transaction.Status)
+		assert.Equal(t, "chewatatest-6669", charge.Transaction.TransID)
+		assert.Equal(t, "AP634JFwE8ZI", charge.Transaction.RefID)
+		assert.Equal(t, "3.5", charge.Transaction.Charge)
+		assert.True(t, decimal.NewFromInt(100).Equal(charge.Transaction.Amount))
+		assert.Equal(t, "telebirr", charge.Transaction.PaymentMethod)
+		assert.Equal(t, "Bilen", charge.Transaction.Customer.FirstName)
+		assert.Equal(t, "Payment", charge.Customization.Title)
+		assert.Equal(t, time.Date(2023, 9, 26, 12, 11, 25, 0, time.UTC), charge.UpdatedAt)
+	})
+
+	t.Run("charge event with an unexpected timestamp format", func(t *testing.T) {
+		event, err := ParseEvent([]byte(`{
+			"event": "charge.success",
+			"amount": "100.00",
+			"status": "success",
+			"tx_ref": "chewatatest-6669",
+			"created_at": "Tue, 26 Sep 2023 12:11:10 GMT",
+			"updated_at": "2023-09-26T12:11:25.000000Z"
+		}`))
+		assert.NoError(t, err)
+
+		charge := event.Charge
+		assert.Equal(t, "chewatatest-6669", charge.Transaction.TransID)
+		assert.Equal(t, "Tue, 26 Sep 2023 12:11:10 GMT", charge.Transaction.CreatedAt)
+		assert.True(t, charge.CreatedAt.IsZero())
+		assert.Equal(t, time.Date(2023, 9, 26, 12, 11, 25, 0, time.UTC), charge.UpdatedAt)
+	})
+
+	t.Run("payout event", func(t *testing.T) {
+		event, err := ParseEvent([]byte(`{
+			"event": "payout.success",
+			"type": "Payout",
+			"account_name": "Israel Goytom",
+			"account_number": "32423423",
+			"bank_id": 946,
+			"bank_name": "Awash Bank",
+			"amount": "100.00",
+			"charge": "1.00",
+			"currency": "ETB",
+			"status": "success",
+			"reference": "3241342142sfdd",
+			"chapa_reference": "PO8Xtdrt3S",
+			"bank_reference": "FT23269MZGV0",
+			"created_at": "2023-09-26T12:11:10.000000Z",
+			"updated_at": "2023-09-26T12:11:25.000000Z"
+		}`))
+		assert.NoError(t, err)
+
+		assert.Equal(t, EventPayoutSuccess, event.Type)
+		assert.Nil(t, event.Charge)
+
+		transfer := event.Payout.Transfer
+		assert.Equal(t, chapa.SuccessTransferStatus, transfer.Status)
+		assert.Equal(t, "3241342142sfdd", transfer.Reference)
+		assert.Equal(t, "946", transfer.BankCode)
+		assert.Equal(t, "PO8Xtdrt3S", transfer.ChapaReference)
+		assert.Equal(t, "FT23269MZGV0", transfer.CrossPartyReference)
+		assert.True(t, decimal.NewFromInt(1).Equal(transfer.Charge))
+	})
+
+	t.Run("unknown event keeps the raw body", func(t *testing.T) {
+		event, err := ParseEvent([]byte(`{"event":"charge.dispute","tx_ref":"ref"}`))
+		assert.NoError(t, err)
+		assert.Equal(t, EventType("charge.dispute"), event.Type)
+		assert.NotNil(t, event.Charge)
+
+		event, err = ParseEvent([]byte(`{"event":"account.updated"}`))
+		assert.NoError(t, err)
+		assert.Nil(t, event.Charge)
+		assert.Nil(t, event.Payout)
+		assert.JSONEq(t, `{"event":"account.updated"}`, string(event.Raw))
+	})
+
+	t.Run("malformed events", func(t *testing.T) {
+		_, err := ParseEvent([]byte(`not json`))
+		assert.ErrorIs(t, err, ErrMalformedEvent)
+
+		_, err = ParseEvent([]byte(`{"tx_ref":"ref"}`))
+		assert.ErrorIs(t, err, ErrMalformedEvent)
+
+		_, err = ParseEvent([]byte(`{"event":"charge.success","amount":"a hundred"}`))
+		assert.ErrorIs(t, err, ErrMalformedEvent)
+	})
+}
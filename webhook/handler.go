@@ -0,0 +1,186 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+
+	chapa "github.com/Chapa-Et/chapa-go"
+)
+
+// DefaultMaxBodyBytes is the largest delivery body a Handler accepts by default.
+const DefaultMaxBodyBytes = 1 << 20
+
+type (
+	// ChargeHandlerFunc handles a charge event. Returning an error makes the
+	// Handler answer with a 500 so that Chapa delivers the event again.
+	ChargeHandlerFunc func(ctx context.Context, event *ChargeEvent) error
+
+	// PayoutHandlerFunc handles a payout event. Returning an error makes the
+	// Handler answer with a 500 so that Chapa delivers the event again.
+	PayoutHandlerFunc func(ctx context.Context, event *PayoutEvent) error
+
+	// EventHandlerFunc handles any event without a more specific handler.
+	EventHandlerFunc func(ctx context.Context, event *Event) error
+)
+
+// Handler is an http.Handler that verifies, decodes and dispatches webhook
+// deliveries to the registered callbacks. Callbacks must be registered before
+// the Handler starts serving.
+//
+// It answers 405 for methods other than POST, 413 for oversized bodies, 401
+// for missing or invalid signatures, 400 for malformed events and 500 when a
+// callback fails. Events without a callback are acknowledged with a 200.
+// With a ReplayGuard, duplicates are acknowledged without being dispatched.
+type Handler struct {
+	verifier     *Verifier
+	maxBodyBytes int64
+	logger       chapa.Logger
+	charge       map[EventType]ChargeHandlerFunc
+	payout       map[EventType]PayoutHandlerFunc
+	fallback     EventHandlerFunc
+	guard        *ReplayGuard
+}
+
+// HandlerOption configures a Handler created with NewHandler.
+type HandlerOption func(*Handler)
+
+// WithMaxBodyBytes limits the size of the delivery bodies the Handler reads.
+func WithMaxBodyBytes(n int64) HandlerOption {
+	return func(h *Handler) {
+		h.maxBodyBytes = n
+	}
+}
+
+// WithLogger sets the logger used to report rejected deliveries and failed callbacks.
+func WithLogger(logger chapa.Logger) HandlerOption {
+	return func(h *Handler) {
+		h.logger = logger
+	}
+}
+
+// WithReplayGuard makes the Handler acknowledge duplicate deliveries without
+// dispatching them again and reject stale ones with a 400.
+func WithReplayGuard(guard *ReplayGuard) HandlerOption {
+	return func(h *Handler) {
+		h.guard = guard
+	}
+}
+
+// NewHandler creates a Handler that checks deliveries with verifier.
+func NewHandler(verifier *Verifier, opts ...HandlerOption) *Handler {
+	h := &Handler{
+		verifier:     verifier,
+		maxBodyBytes: DefaultMaxBodyBytes,
+		logger:       log.Default(),
+		charge:       make(map[EventType]ChargeHandlerFunc),
+		payout:       make(map[EventType]PayoutHandlerFunc),
+	}
+
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	return h
+}
+
+// OnCharge registers fn for the given charge event type.
+func (h *Handler) OnCharge(eventType EventType, fn ChargeHandlerFunc) {
+	h.charge[eventType] = fn
+}
+
+// OnPayout registers fn for the given payout event type.
+func (h *Handler) OnPayout(eventType EventType, fn PayoutHandlerFunc) {
+	h.payout[eventType] = fn
+}
+
+// OnEvent registers fn for every event without a more specific callback.
+func (h *Handler) OnEvent(fn EventHandlerFunc) {
+	h.fallback = fn
+}
+
+func (h *Handler) OnChargeSuccess(fn ChargeHandlerFunc)   { h.OnCharge(EventChargeSuccess, fn) }
+func (h *Handler) OnChargeFailed(fn ChargeHandlerFunc)    { h.OnCharge(EventChargeFailed, fn) }
+func (h *Handler) OnChargeCancelled(fn ChargeHandlerFunc) { h.OnCharge(EventChargeCancelled, fn) }
+func (h *Handler) OnChargeRefunded(fn ChargeHandlerFunc)  { h.OnCharge(EventChargeRefunded, fn) }
+func (h *Handler) OnChargeReversed(fn ChargeHandlerFunc)  { h.OnCharge(EventChargeReversed, fn) }
+func (h *Handler) OnPayoutSuccess(fn PayoutHandlerFunc)   { h.OnPayout(EventPayoutSuccess, fn) }
+func (h *Handler) OnPayoutFailed(fn PayoutHandlerFunc)    { h.OnPayout(EventPayoutFailed, fn) }
+func (h *Handler) OnPayoutReversed(fn PayoutHandlerFunc)  { h.OnPayout(EventPayoutReversed, fn) }
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, h.maxBodyBytes))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	if err = h.verifier.VerifyRequest(r.Header, body); err != nil {
+		h.logger.Printf("warning rejected webhook delivery: %v", err)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	event, err := ParseEvent(body)
+	if err != nil {
+		h.logger.Printf("warning rejected webhook delivery: %v", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if h.guard != nil {
+		err = h.guard.Check(r.Context(), event.ID(), event.Timestamp())
+		switch {
+		case errors.Is(err, ErrDuplicateEvent):
+			w.WriteHeader(http.StatusOK)
+			return
+		case errors.Is(err, ErrStaleEvent):
+			h.logger.Printf("warning rejected webhook delivery: %v", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		case err != nil:
+			h.logger.Printf("error while checking webhook event %v: %v", event.Type, err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if err = h.dispatch(r.Context(), event); err != nil {
+		h.logger.Printf("error while handling webhook event %v: %v", event.Type, err)
+		if h.guard != nil {
+			if err := h.guard.Release(r.Context(), event.ID()); err != nil {
+				h.logger.Printf("error while releasing webhook event %v: %v", event.Type, err)
+			}
+		}
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *Handler) dispatch(ctx context.Context, event *Event) error {
+	if fn, ok := h.charge[event.Type]; ok && event.Charge != nil {
+		return fn(ctx, event.Charge)
+	}
+	if fn, ok := h.payout[event.Type]; ok && event.Payout != nil {
+		return fn(ctx, event.Payout)
+	}
+	if h.fallback != nil {
+		return h.fallback(ctx, event)
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandler(t *testing.T) {
+	verifier := NewVerifier("webhook-secret")
+	discard := log.New(io.Discard, "", 0)
+
+	deliver := func(handler http.Handler, body string, sign bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/webhooks/chapa", strings.NewReader(body))
+		if sign {
+			req.Header.Set(SignatureHeader, verifier.Sign([]byte(body)))
+		}
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("dispatches charge events", func(t *testing.T) {
+		handler := NewHandler(verifier, WithLogger(discard))
+
+		var got *ChargeEvent
+		handler.OnChargeSuccess(func(_ context.Context, event *ChargeEvent) error {
+			got = event
+			return nil
+		})
+		handler.OnChargeFailed(func(context.Context, *ChargeEvent) error {
+			t.Fatal("unexpected charge.failed callback")
+			return nil
+		})
+
+		rec := deliver(handler, `{"event":"charge.success","tx_ref":"chewatatest-6669","status":"success"}`, true)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "chewatatest-6669", got.Transaction.TransID)
+	})
+
+	t.Run("dispatches payout events", func(t *testing.T) {
+		handler := NewHandler(verifier, WithLogger(discard))
+
+		var got *PayoutEvent
+		handler.OnPayoutFailed(func(_ context.Context, event *PayoutEvent) error {
+			got = event
+			return nil
+		})
+
+		rec := deliver(handler, `{"event":"payout.failed","reference":"3241342142sfdd","status":"failed"}`, true)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "3241342142sfdd", got.Transfer.Reference)
+	})
+
+	t.Run("falls back for other events and acknowledges unhandled ones", func(t *testing.T) {
+		handler := NewHandler(verifier, WithLogger(discard))
+		rec := deliver(handler, `{"event":"charge.refunded","tx_ref":"ref"}`, true)
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var got EventType
+		handler.OnEvent(func(_ context.Context, event *Event) error {
+			got = event.Type
+			return nil
+		})
+		rec = deliver(handler, `{"event":"charge.refunded","tx_ref":"ref"}`, true)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, EventChargeRefunded, got)
+	})
+
+	t.Run("asks chapa to retry when a callback fails", func(t *testing.T) {
+		handler := NewHandler(verifier, WithLogger(discard))
+		handler.OnChargeSuccess(func(context.Context, *ChargeEvent) error {
+			return errors.New("database unavailable")
+		})
+
+		rec := deliver(handler, `{"event":"charge.success","tx_ref":"ref"}`, true)
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	})
+
+	t.Run("rejects bad deliveries", func(t *testing.T) {
+		handler := NewHandler(verifier, WithLogger(discard), WithMaxBodyBytes(64))
+
+		rec := deliver(handler, `{"event":"charge.success","tx_ref":"ref"}`, false)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+		rec = deliver(handler, `{"tx_ref":"ref"}`, true)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+		rec = deliver(handler, `{"event":"charge.success","tx_ref":"`+strings.Repeat("x", 64)+`"}`, true)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+
+		req := httptest.NewRequest(http.MethodGet, "/webhooks/chapa", nil)
+		rec = httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+		assert.Equal(t, http.MethodPost, rec.Header().Get("Allow"))
+	})
+}
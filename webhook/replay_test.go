@@ -0,0 +1,157 @@
+package webhook
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReplayGuard(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	newGuard := func() *ReplayGuard {
+		store := NewMemoryStore()
+		store.now = func() time.Time { return now }
+
+		guard := NewReplayGuard(store, time.Hour)
+		guard.now = func() time.Time { return now }
+		return guard
+	}
+
+	t.Run("rejects duplicates until released", func(t *testing.T) {
+		guard := newGuard()
+
+		assert.NoError(t, guard.Check(ctx, "charge.success:ref", now.Add(-time.Minute)))
+		assert.ErrorIs(t, guard.Check(ctx, "charge.success:ref", now.Add(-time.Minute)), ErrDuplicateEvent)
+		assert.NoError(t, guard.Check(ctx, "charge.refunded:ref", now))
+
+		assert.NoError(t, guard.Release(ctx, "charge.success:ref"))
+		assert.NoError(t, guard.Check(ctx, "charge.success:ref", now))
+	})
+
+	t.Run("rejects stale events", func(t *testing.T) {
+		guard := newGuard()
+
+		assert.ErrorIs(t, guard.Check(ctx, "charge.success:old", now.Add(-2*time.Hour)), ErrStaleEvent)
+		assert.NoError(t, guard.Check(ctx, "callback:ref", time.Time{}))
+	})
+
+	t.Run("defaults a non-positive max age", func(t *testing.T) {
+		store := NewMemoryStore()
+		store.now = func() time.Time { return now }
+
+		guard := NewReplayGuard(store, 0)
+		guard.now = func() time.Time { return now }
+
+		assert.Equal(t, DefaultMaxAge, guard.maxAge)
+		assert.NoError(t, guard.Check(ctx, "charge.success:ref", now.Add(-time.Minute)))
+		assert.ErrorIs(t, guard.Check(ctx, "charge.success:ref", now.Add(-time.Minute)), ErrDuplicateEvent)
+	})
+
+	t.Run("memory store expires ids", func(t *testing.T) {
+		store := NewMemoryStore()
+		store.now = func() time.Time { return now }
+
+		added, err := store.Add(ctx, "id", now.Add(time.Minute))
+		assert.NoError(t, err)
+		assert.True(t, added)
+
+		added, _ = store.Add(ctx, "id", now.Add(time.Minute))
+		assert.False(t, added)
+
+		store.now = func() time.Time { return now.Add(2 * time.Minute) }
+		added, _ = store.Add(ctx, "id", now.Add(time.Hour))
+		assert.True(t, added)
+	})
+
+	t.Run("file store survives reopening", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "events.jsonl")
+
+		store, err := NewFileStore(path)
+		assert.NoError(t, err)
+
+		future := time.Now().Add(time.Hour)
+		added, err := store.Add(ctx, "kept", future)
+		assert.NoError(t, err)
+		assert.True(t, added)
+		_, _ = store.Add(ctx, "removed", future)
+		_, _ = store.Add(ctx, "expired", time.Now().Add(-time.Second))
+		assert.NoError(t, store.Remove(ctx, "removed"))
+		assert.NoError(t, store.Close())
+
+		store, err = NewFileStore(path)
+		assert.NoError(t, err)
+		defer store.Close()
+
+		added, _ = store.Add(ctx, "kept", future)
+		assert.False(t, added)
+		added, _ = store.Add(ctx, "removed", future)
+		assert.True(t, added)
+		added, _ = store.Add(ctx, "expired", future)
+		assert.True(t, added)
+	})
+}
+
+func TestHandlerReplayGuard(t *testing.T) {
+	verifier := NewVerifier("webhook-secret")
+	guard := NewReplayGuard(NewMemoryStore(), time.Hour)
+	handler := NewHandler(verifier, WithReplayGuard(guard), WithLogger(log.New(io.Discard, "", 0)))
+
+	calls := 0
+	fail := true
+	handler.OnChargeSuccess(func(context.Context, *ChargeEvent) error {
+		calls++
+		if fail {
+			return io.ErrUnexpectedEOF
+		}
+		return nil
+	})
+
+	deliver := func(body string) int {
+		req := httptest.NewRequest(http.MethodPost, "/webhooks/chapa", strings.NewReader(body))
+		req.Header.Set(SignatureHeader, verifier.Sign([]byte(body)))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	fresh := `{"event":"charge.success","tx_ref":"ref","updated_at":"` + time.Now().UTC().Format(time.RFC3339) + `"}`
+
+	assert.Equal(t, http.StatusInternalServerError, deliver(fresh))
+	fail = false
+	assert.Equal(t, http.StatusOK, deliver(fresh))
+	assert.Equal(t, http.StatusOK, deliver(fresh))
+	assert.Equal(t, 2, calls)
+
+	// a redelivery whose body differs only in updated_at is still a duplicate
+	redelivered := `{"event":"charge.success","tx_ref":"ref","updated_at":"` + time.Now().UTC().Add(time.Second).Format(time.RFC3339Nano) + `"}`
+	assert.Equal(t, http.StatusOK, deliver(redelivered))
+	assert.Equal(t, 2, calls)
+
+	refunded := 0
+	handler.OnChargeRefunded(func(context.Context, *ChargeEvent) error {
+		refunded++
+		return nil
+	})
+	refund := func(amount string) string {
+		return `{"event":"charge.refunded","tx_ref":"ref","amount":` + amount + `,"updated_at":"` + time.Now().UTC().Format(time.RFC3339) + `"}`
+	}
+	first, second := refund("40"), refund("60")
+	assert.Equal(t, http.StatusOK, deliver(first))
+	assert.Equal(t, http.StatusOK, deliver(second))
+	assert.Equal(t, http.StatusOK, deliver(first))
+	assert.Equal(t, 2, refunded)
+
+	stale := `{"event":"charge.success","tx_ref":"old","updated_at":"2020-01-01T00:00:00Z"}`
+	assert.Equal(t, http.StatusBadRequest, deliver(stale))
+	assert.Equal(t, 2, calls)
+}
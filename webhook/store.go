@@ -0,0 +1,203 @@
+package webhook
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often a MemoryStore drops expired IDs.
+const sweepInterval = time.Minute
+
+// MemoryStore is an EventStore kept in process memory. IDs are dropped once
+// they expire.
+type MemoryStore struct {
+	mu        sync.Mutex
+	expiries  map[string]time.Time
+	nextSweep time.Time
+	now       func() time.Time
+}
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		expiries: make(map[string]time.Time),
+		now:      time.Now,
+	}
+}
+
+func (s *MemoryStore) Add(_ context.Context, id string, expiresAt time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if now.After(s.nextSweep) {
+		for key, expiry := range s.expiries {
+			if !now.Before(expiry) {
+				delete(s.expiries, key)
+			}
+		}
+		s.nextSweep = now.Add(sweepInterval)
+	}
+
+	if expiry, ok := s.expiries[id]; ok && now.Before(expiry) {
+		return false, nil
+	}
+
+	s.expiries[id] = expiresAt
+	return true, nil
+}
+
+func (s *MemoryStore) Remove(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.expiries, id)
+	return nil
+}
+
+// FileStore is an EventStore that survives restarts by appending every change
+// to a file. Expired IDs are dropped from the file when it is opened.
+type FileStore struct {
+	mu     sync.Mutex
+	memory *MemoryStore
+	file   *os.File
+}
+
+// fileStoreRecord is one line of a FileStore file.
+type fileStoreRecord struct {
+	ID        string    `json:"id"`
+	ExpiresAt time.Time `json:"expires_at,omitempty"`
+	Removed   bool      `json:"removed,omitempty"`
+}
+
+// NewFileStore opens the FileStore kept at path, creating it if needed.
+func NewFileStore(path string) (*FileStore, error) {
+	memory := NewMemoryStore()
+
+	if err := loadFileStore(path, memory); err != nil {
+		return nil, err
+	}
+	if err := compactFileStore(path, memory); err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FileStore{
+		memory: memory,
+		file:   file,
+	}, nil
+}
+
+func (s *FileStore) Add(ctx context.Context, id string, expiresAt time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	added, err := s.memory.Add(ctx, id, expiresAt)
+	if err != nil || !added {
+		return added, err
+	}
+
+	if err = s.append(fileStoreRecord{ID: id, ExpiresAt: expiresAt}); err != nil {
+		_ = s.memory.Remove(ctx, id)
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (s *FileStore) Remove(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.memory.Remove(ctx, id); err != nil {
+		return err
+	}
+
+	return s.append(fileStoreRecord{ID: id, Removed: true})
+}
+
+// Close closes the underlying file.
+func (s *FileStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.file.Close()
+}
+
+func (s *FileStore) append(record fileStoreRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.file.Write(append(line, '\n'))
+	return err
+}
+
+// loadFileStore replays the records of the file at path into memory.
+func loadFileStore(path string, memory *MemoryStore) error {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	now := memory.now()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var record fileStoreRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			// a torn last line from a crash is not worth failing for
+			continue
+		}
+
+		if record.Removed || !now.Before(record.ExpiresAt) {
+			delete(memory.expiries, record.ID)
+			continue
+		}
+		memory.expiries[record.ID] = record.ExpiresAt
+	}
+
+	return scanner.Err()
+}
+
+// compactFileStore rewrites the file at path with only the live IDs.
+func compactFileStore(path string, memory *MemoryStore) error {
+	temp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+
+	writer := bufio.NewWriter(temp)
+	for id, expiresAt := range memory.expiries {
+		line, err := json.Marshal(fileStoreRecord{ID: id, ExpiresAt: expiresAt})
+		if err != nil {
+			temp.Close()
+			return err
+		}
+		writer.Write(append(line, '\n'))
+	}
+
+	if err = writer.Flush(); err != nil {
+		temp.Close()
+		return err
+	}
+	if err = temp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(temp.Name(), path)
+}
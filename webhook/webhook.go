@@ -0,0 +1,82 @@
+// Package webhook verifies and decodes webhook deliveries sent by Chapa.
+//
+// Chapa signs every delivery with an HMAC-SHA256 of the request body, keyed
+// with the webhook secret configured in the merchant dashboard, and sends the
+// hex-encoded signature in the Chapa-Signature and x-chapa-signature headers.
+//
+// Hits on a payment's CallbackURL are not signed; CallbackHandler verifies
+// them with the Chapa API instead.
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+const (
+	SignatureHeader  = "Chapa-Signature"
+	XSignatureHeader = "X-Chapa-Signature"
+)
+
+var (
+	ErrMissingSignature = errors.New("webhook: missing signature")
+	ErrInvalidSignature = errors.New("webhook: invalid signature")
+)
+
+// Verifier checks webhook signatures against the merchant's webhook secret.
+type Verifier struct {
+	secret []byte
+}
+
+// NewVerifier creates a Verifier for the given webhook secret.
+func NewVerifier(secret string) *Verifier {
+	return &Verifier{secret: []byte(secret)}
+}
+
+// Sign returns the hex-encoded signature Chapa sends for body.
+func (v *Verifier) Sign(body []byte) string {
+	return hex.EncodeToString(v.mac(body))
+}
+
+// Verify checks signature against body in constant time.
+func (v *Verifier) Verify(body []byte, signature string) error {
+	signature = strings.TrimSpace(signature)
+	if signature == "" {
+		return ErrMissingSignature
+	}
+
+	given, err := hex.DecodeString(strings.TrimPrefix(signature, "sha256="))
+	if err != nil || !hmac.Equal(given, v.mac(body)) {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
+
+// VerifyRequest checks the signature headers of a delivery against its body.
+// The delivery is accepted when either header carries a valid signature.
+func (v *Verifier) VerifyRequest(header http.Header, body []byte) error {
+	err := ErrMissingSignature
+	for _, name := range []string{SignatureHeader, XSignatureHeader} {
+		signature := header.Get(name)
+		if signature == "" {
+			continue
+		}
+
+		if err = v.Verify(body, signature); err == nil {
+			return nil
+		}
+	}
+
+	return err
+}
+
+func (v *Verifier) mac(body []byte) []byte {
+	mac := hmac.New(sha256.New, v.secret)
+	mac.Write(body)
+	return mac.Sum(nil)
+}
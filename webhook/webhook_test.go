@@ -0,0 +1,48 @@
+package webhook
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifier(t *testing.T) {
+	verifier := NewVerifier("webhook-secret")
+	body := []byte(`{"event":"charge.success","tx_ref":"chewatatest-6669"}`)
+
+	t.Run("accepts a valid signature", func(t *testing.T) {
+		assert.NoError(t, verifier.Verify(body, verifier.Sign(body)))
+		assert.NoError(t, verifier.Verify(body, "sha256="+verifier.Sign(body)))
+	})
+
+	t.Run("rejects a signature for another body", func(t *testing.T) {
+		signature := verifier.Sign([]byte(`{"event":"charge.success","tx_ref":"other"}`))
+		assert.ErrorIs(t, verifier.Verify(body, signature), ErrInvalidSignature)
+	})
+
+	t.Run("rejects a signature made with another secret", func(t *testing.T) {
+		signature := NewVerifier("other-secret").Sign(body)
+		assert.ErrorIs(t, verifier.Verify(body, signature), ErrInvalidSignature)
+	})
+
+	t.Run("rejects garbage and missing signatures", func(t *testing.T) {
+		assert.ErrorIs(t, verifier.Verify(body, "not-hex"), ErrInvalidSignature)
+		assert.ErrorIs(t, verifier.Verify(body, ""), ErrMissingSignature)
+	})
+
+	t.Run("verifies either signature header", func(t *testing.T) {
+		header := http.Header{}
+		assert.ErrorIs(t, verifier.VerifyRequest(header, body), ErrMissingSignature)
+
+		header.Set("x-chapa-signature", verifier.Sign(body))
+		assert.NoError(t, verifier.VerifyRequest(header, body))
+
+		header = http.Header{}
+		header.Set(SignatureHeader, "00")
+		assert.ErrorIs(t, verifier.VerifyRequest(header, body), ErrInvalidSignature)
+
+		header.Set(XSignatureHeader, verifier.Sign(body))
+		assert.NoError(t, verifier.VerifyRequest(header, body))
+	})
+}